@@ -1,28 +1,190 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/admin"
 	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+	"github.com/johnqing-424/WeChat-RAG/internal/moderation"
+	"github.com/johnqing-424/WeChat-RAG/internal/queue"
+	"github.com/johnqing-424/WeChat-RAG/internal/ragflow"
+	"github.com/johnqing-424/WeChat-RAG/internal/ratelimit"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
 	"github.com/johnqing-424/WeChat-RAG/internal/wechat"
 )
 
 func main() {
-	// 获取配置
-	cfg := config.GetConfig()
+	configPath := flag.String("config", os.Getenv("WECHAT_RAG_CONFIG"), "配置文件路径，未指定时依次查找 ./config.yml、../config.yml、~/config.yml")
+	flag.Parse()
 
-	r := gin.Default()
+	// 加载配置，环境变量优先于配置文件
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+
+	// 自定义菜单管理子命令
+	if args := flag.Args(); len(args) > 0 && args[0] == "menu" {
+		os.Exit(runMenuCommand(args[1:]))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "配置无效:\n%v\n", err)
+		os.Exit(1)
+	}
+	logging.Setup(cfg.Log)
+
+	// 创建存储和任务队列，所有公众号共用
+	st, err := store.New(cfg.Store)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+
+	pool := queue.NewPool(cfg.Queue.Workers, cfg.Queue.Size)
+	limiter := ratelimit.New(cfg.Limit)
+	moderator, err := moderation.New(cfg.Moderation)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+	registerCacheMetrics(st, pool, limiter)
+
+	// 每个公众号使用各自的 access_token 缓存、RAGFlow客户端和消息处理器
+	rl := newReloader(cfg)
+	rl.limiter, rl.moderator = limiter, moderator
+	var handlers []*wechat.Handler
+	pendingHandlers := make(map[string]pendingHandler)
+	for _, account := range cfg.AccountList() {
+		ragClient := ragflow.NewClient(account.RagFlow, st)
+		h := wechat.NewHandler(wechat.NewAccount(account), ragClient, st, pool, limiter, moderator, account.RagFlow)
+		handlers = append(handlers, h)
+		pendingHandlers[account.Name] = h
+		rl.addAccount(account, h, ragClient)
+		slog.Info("已加载公众号账号", "account", account.Name, "path", "/wechat/"+account.Name)
+	}
+
+	// 使用结构化访问日志替代 gin 默认的文本日志，debug 级别时保留 gin 的调试输出
+	if logging.ParseLevel(cfg.Log.Level) != slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	r.Use(logging.Middleware(), gin.Recovery())
+
+	// 微信回调，/wechat/:account 按路径选择公众号，/wechat 按消息的 ToUserName 选择
+	wechat.NewRouter(handlers...).Register(r)
+
+	// 企业微信自建应用回调，使用默认的RAGFlow配置
+	if cfg.WeCom.Enabled {
+		wecomClient := ragflow.NewClient(cfg.RagFlow, st)
+		wecom := wechat.NewWeComHandler(wechat.NewWeComApp(cfg.WeCom), wecomClient, st, pool, limiter, moderator, cfg.RagFlow)
+		r.GET("/wecom", wecom.VerifyURL)
+		r.POST("/wecom", wecom.HandleMessage)
+		handlers = append(handlers, wecom.Handler())
+		pendingHandlers["wecom"] = wecom
+		rl.wecom, rl.wecomClient = wecom, wecomClient
+		slog.Info("已启用企业微信回调", "path", "/wecom")
+	}
 
-	// 微信 Token 验证（GET 请求）
-	r.GET("/wechat", wechat.VerifyWeChatToken)
+	// 管理接口，用于排查用户的提问、答案缓存和RAGFlow会话
+	if cfg.Admin.Enabled {
+		rl.admin = admin.NewServer(cfg.Admin, st, handlers...)
+		rl.admin.Register(r)
+		slog.Info("已启用管理接口", "path", "/admin")
+	}
+
+	// 配置文件修改或收到 SIGHUP 时热更新配置
+	config.Watch(time.Duration(cfg.Server.ConfigWatchInterval)*time.Second, rl.apply)
 
-	// 接收用户消息（POST 请求）
-	r.POST("/wechat", wechat.HandleWeChatMessage)
+	// Prometheus 监控指标
+	r.GET("/metrics", metrics.Handler)
+
+	// 继续处理上次停止时未完成的问题
+	resumePending(cfg.Server, st, pendingHandlers, handlers[0])
 
 	// 启动 Gin Web 服务
-	portAddr := fmt.Sprintf(":%d", cfg.Server.Port)
-	if err := r.Run(portAddr); err != nil {
-		panic(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Server.Port), Handler: r}
+	go func() {
+		slog.Info("服务启动", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("服务启动失败", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(cfg.Server, srv, pool, st, handlers)
+}
+
+// shutdown 停止接收新消息，在超时前等待处理中的答案完成推送，仍未完成的问题保存到文件
+func shutdown(cfg config.ServerConfig, srv *http.Server, pool *queue.Pool, st store.Store, handlers []*wechat.Handler) {
+	timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	slog.Info("正在停止服务，等待处理中的答案", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// 先关闭监听并等待进行中的回调返回，再等待队列中的问题和后台推送
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("等待进行中的请求超时", "error", err)
+	}
+	err := pool.Shutdown(ctx)
+	for _, h := range handlers {
+		if err != nil {
+			break
+		}
+		err = h.Wait(ctx)
+	}
+	if err != nil {
+		slog.Warn("等待处理中的答案超时", "error", err)
+	}
+
+	savePending(cfg, st)
+	slog.Info("服务已停止")
+}
+
+// registerCacheMetrics 注册存储、任务队列和分段缓存的条目数指标
+func registerCacheMetrics(st store.Store, pool *queue.Pool, limiter *ratelimit.Limiter) {
+	storeStat := func(pick func(store.Stats) int) func() float64 {
+		return func() float64 {
+			stats, err := st.Stats()
+			if err != nil {
+				slog.Error("读取存储统计失败", "error", err)
+				return 0
+			}
+			return float64(pick(stats))
+		}
 	}
+	metrics.CacheSize.Set("messages", storeStat(func(s store.Stats) int { return s.Messages }))
+	metrics.CacheSize.Set("answers", storeStat(func(s store.Stats) int { return s.Answers }))
+	metrics.CacheSize.Set("sessions", storeStat(func(s store.Stats) int { return s.Sessions }))
+
+	metrics.CacheSize.Set("more_parts", func() float64 { return float64(wechat.MoreCacheSize()) })
+	metrics.CacheSize.Set("rate_limit_users", func() float64 { return float64(limiter.Len()) })
+	metrics.CacheSize.Set("queue_waiting", func() float64 {
+		queued, _ := pool.Stats()
+		return float64(queued)
+	})
+	metrics.CacheSize.Set("queue_running", func() float64 {
+		_, running := pool.Stats()
+		return float64(running)
+	})
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/wechat"
+)
+
+const menuUsage = `用法: wechat-rag [-config 配置文件] menu <create|get|delete|validate> [-account 名称] [参数]
+
+  create   -file menu.yml [-dry-run]  校验并创建自定义菜单
+  get                                 查询当前自定义菜单
+  delete   [-dry-run]                 删除自定义菜单
+  validate -file menu.yml             仅校验菜单定义并打印JSON
+
+  -account 指定 accounts 中的公众号，未指定时使用第一个账号
+`
+
+// runMenuCommand 执行自定义菜单管理子命令，返回进程退出码
+func runMenuCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, menuUsage)
+		return 2
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("menu "+action, flag.ContinueOnError)
+	file := fs.String("file", "menu.yml", "菜单定义YAML文件")
+	dryRun := fs.Bool("dry-run", false, "只打印将要提交的内容，不调用微信接口")
+	accountName := fs.String("account", "", "公众号账号名称")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	account, err := menuAccount(*accountName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		return 2
+	}
+
+	switch action {
+	case "create", "validate":
+		err = createMenu(account, *file, *dryRun || action == "validate")
+	case "get":
+		var menu []byte
+		menu, err = account.GetMenu()
+		if err == nil {
+			fmt.Println(string(menu))
+		}
+	case "delete":
+		if *dryRun {
+			fmt.Println("dry-run: 将删除公众号当前的自定义菜单")
+			return 0
+		}
+		err = account.DeleteMenu()
+		if err == nil {
+			fmt.Println("自定义菜单已删除")
+		}
+	default:
+		fmt.Fprint(os.Stderr, menuUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		return 1
+	}
+	return 0
+}
+
+// menuAccount 返回要管理菜单的公众号账号
+func menuAccount(name string) (*wechat.Account, error) {
+	cfg := config.GetConfig()
+	if name == "" {
+		return wechat.NewAccount(cfg.AccountList()[0]), nil
+	}
+
+	account, ok := cfg.FindAccount(name)
+	if !ok {
+		return nil, fmt.Errorf("未找到公众号账号: %s", name)
+	}
+	return wechat.NewAccount(account), nil
+}
+
+// createMenu 读取并校验菜单定义，dryRun 时只打印JSON
+func createMenu(account *wechat.Account, file string, dryRun bool) error {
+	menu, err := wechat.LoadMenu(file)
+	if err != nil {
+		return err
+	}
+	if err := menu.Validate(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		body, err := menu.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(body))
+		return nil
+	}
+
+	if err := account.CreateMenu(menu); err != nil {
+		return err
+	}
+	fmt.Println("自定义菜单已创建")
+	return nil
+}
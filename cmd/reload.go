@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/admin"
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/moderation"
+	"github.com/johnqing-424/WeChat-RAG/internal/ragflow"
+	"github.com/johnqing-424/WeChat-RAG/internal/ratelimit"
+	"github.com/johnqing-424/WeChat-RAG/internal/wechat"
+)
+
+// reloadTarget 是一个公众号的消息处理器及其RAGFlow客户端
+type reloadTarget struct {
+	account config.AccountConfig
+	handler *wechat.Handler
+	client  *ragflow.Client
+}
+
+// reloader 在配置热更新时把新配置应用到各组件，答案记录、会话和 access_token 等状态保留
+type reloader struct {
+	current  *config.Config
+	accounts map[string]*reloadTarget
+
+	wecom       *wechat.WeComHandler
+	wecomClient *ragflow.Client
+	admin       *admin.Server
+	limiter     *ratelimit.Limiter
+	moderator   *moderation.Moderator
+}
+
+func newReloader(cfg *config.Config) *reloader {
+	return &reloader{current: cfg, accounts: make(map[string]*reloadTarget)}
+}
+
+// addAccount 登记公众号的处理器和RAGFlow客户端
+func (r *reloader) addAccount(account config.AccountConfig, h *wechat.Handler, client *ragflow.Client) {
+	r.accounts[account.Name] = &reloadTarget{account: account, handler: h, client: client}
+}
+
+// apply 应用新配置；监听端口、停止服务的设置、存储、任务队列、账号增减和启用的功能等在启动时确定，修改后需要重启
+func (r *reloader) apply(cfg *config.Config) {
+	old := r.current
+	r.current = cfg
+
+	logging.Setup(cfg.Log)
+	r.limiter.UpdateConfig(cfg.Limit)
+	if err := r.moderator.UpdateConfig(cfg.Moderation); err != nil {
+		slog.Error("更新内容审核配置失败，继续使用原敏感词表", "error", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, account := range cfg.AccountList() {
+		seen[account.Name] = true
+		target, ok := r.accounts[account.Name]
+		if !ok {
+			slog.Warn("新增的公众号账号需要重启后生效", "account", account.Name)
+			continue
+		}
+		if account.OriginalID != target.account.OriginalID {
+			slog.Warn("公众号原始ID的修改需要重启后生效", "account", account.Name)
+		}
+		target.account = account
+		target.handler.UpdateConfig(account)
+		target.client.UpdateConfig(account.RagFlow)
+	}
+	for name := range r.accounts {
+		if !seen[name] {
+			slog.Warn("删除的公众号账号需要重启后生效", "account", name)
+		}
+	}
+
+	if r.wecom != nil && cfg.WeCom.Enabled {
+		r.wecom.UpdateConfig(cfg.WeCom, cfg.RagFlow)
+		r.wecomClient.UpdateConfig(cfg.RagFlow)
+	}
+	if r.admin != nil && cfg.Admin.Enabled {
+		r.admin.UpdateConfig(cfg.Admin)
+	}
+
+	restartOnly := map[string]bool{
+		"server.port":             old.Server.Port != cfg.Server.Port,
+		"server.shutdown_timeout": old.Server.ShutdownTimeout != cfg.Server.ShutdownTimeout,
+		"server.pending_file":     old.Server.PendingFile != cfg.Server.PendingFile,
+		"server.resume_pending":   old.Server.ResumePending != cfg.Server.ResumePending,
+		"store":                   !reflect.DeepEqual(old.Store, cfg.Store),
+		"queue":                   !reflect.DeepEqual(old.Queue, cfg.Queue),
+		"wecom.enabled":           old.WeCom.Enabled != cfg.WeCom.Enabled,
+		"admin.enabled":           old.Admin.Enabled != cfg.Admin.Enabled,
+	}
+	for key, changed := range restartOnly {
+		if changed {
+			slog.Warn("该配置的修改需要重启后生效", "key", key)
+		}
+	}
+}
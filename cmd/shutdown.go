@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+)
+
+// pendingHandler 处理上次停止服务时未完成的问题
+type pendingHandler interface {
+	ResumeAnswer(answer *store.Answer) error
+	InterruptAnswer(answer *store.Answer)
+}
+
+// resumePending 读取上次停止时保存的未完成问题，按账号重新处理或告知用户重新发送
+// 账号已被删除的记录交给 fallback 处理
+func resumePending(cfg config.ServerConfig, st store.Store, handlers map[string]pendingHandler, fallback pendingHandler) {
+	if cfg.PendingFile == "" {
+		return
+	}
+	answers, err := store.LoadPending(cfg.PendingFile)
+	if err != nil {
+		slog.Error("读取未完成的问题失败", "file", cfg.PendingFile, "error", err)
+		return
+	}
+	if len(answers) == 0 {
+		return
+	}
+
+	slog.Info("发现上次停止时未完成的问题", "count", len(answers), "resume", cfg.ResumePending)
+	for _, answer := range answers {
+		// 保存后仍在运行的任务可能已写入答案并推送，此时不再重复处理
+		if current, ok, err := st.GetAnswer(answer.MsgID); err == nil && ok && (current.IsReady || current.Pushed) {
+			slog.Info("未完成的问题在停止前已得到答案，跳过", "request_id", answer.MsgID)
+			continue
+		}
+		h, ok := handlers[answer.Account]
+		if !ok {
+			h = fallback
+		}
+		if !cfg.ResumePending {
+			h.InterruptAnswer(answer)
+			continue
+		}
+		if err := h.ResumeAnswer(answer); err != nil {
+			slog.Warn("重新处理未完成的问题失败", "request_id", answer.MsgID, "error", err)
+		}
+	}
+}
+
+// savePending 把仍未准备好答案的问题写入文件，供下次启动时处理
+func savePending(cfg config.ServerConfig, st store.Store) {
+	answers, err := st.ListAnswers("", 0)
+	if err != nil {
+		slog.Error("读取答案记录失败，无法保存未完成的问题", "error", err)
+		return
+	}
+
+	var pending []*store.Answer
+	for _, answer := range answers {
+		if !answer.IsReady {
+			pending = append(pending, answer)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	if cfg.PendingFile == "" {
+		slog.Warn("未配置 pending_file，未完成的问题将丢失", "count", len(pending))
+		return
+	}
+	if err := store.SavePending(cfg.PendingFile, pending); err != nil {
+		slog.Error("保存未完成的问题失败", "file", cfg.PendingFile, "count", len(pending), "error", err)
+		return
+	}
+	slog.Warn("已保存未完成的问题，下次启动时处理", "file", cfg.PendingFile, "count", len(pending))
+}
@@ -0,0 +1,255 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+	"github.com/johnqing-424/WeChat-RAG/internal/wechat"
+)
+
+// 列表接口的默认和最大返回条数
+const (
+	defaultListLimit = 20
+	maxListLimit     = 500
+)
+
+// Server 提供查看用户消息、答案缓存和RAGFlow会话的管理接口
+type Server struct {
+	store    store.Store
+	handlers []*wechat.Handler
+	access   atomic.Pointer[accessControl]
+}
+
+// accessControl 是管理接口的访问令牌和IP白名单
+type accessControl struct {
+	token   string
+	allowed []*net.IPNet
+}
+
+// NewServer 创建管理接口，handlers 为各公众号(及企业微信)的消息处理器，用于查询和清除会话
+func NewServer(cfg config.AdminConfig, st store.Store, handlers ...*wechat.Handler) *Server {
+	s := &Server{store: st, handlers: handlers}
+	s.UpdateConfig(cfg)
+	return s
+}
+
+// UpdateConfig 替换访问令牌和IP白名单，启用或关闭管理接口需要重启
+func (s *Server) UpdateConfig(cfg config.AdminConfig) {
+	access := &accessControl{token: cfg.Token}
+	for _, ip := range cfg.AllowIPs {
+		if !strings.Contains(ip, "/") {
+			if strings.Contains(ip, ":") {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+		if _, network, err := net.ParseCIDR(ip); err == nil {
+			access.allowed = append(access.allowed, network)
+		} else {
+			slog.Warn("管理接口白名单无效，已忽略", "ip", ip)
+		}
+	}
+	s.access.Store(access)
+}
+
+// Register 注册 /admin 路由组
+func (s *Server) Register(r gin.IRouter) {
+	g := r.Group("/admin", s.authenticate)
+
+	g.GET("/answers", s.listAnswers)
+	g.GET("/users/:user/messages", s.listUserMessages)
+	g.GET("/users/:user/session", s.getUserSession)
+	g.DELETE("/users/:user/session", s.clearUserSession)
+
+	g.GET("/access", s.listAccess)
+	g.PUT("/users/:user/access", s.setUserAccess)
+	g.DELETE("/users/:user/access", s.deleteUserAccess)
+}
+
+// authenticate 校验访问令牌和IP白名单，两者都配置时需同时满足
+func (s *Server) authenticate(c *gin.Context) {
+	access := s.access.Load()
+
+	// 使用连接的对端地址，X-Forwarded-For 等请求头可以伪造
+	if len(access.allowed) > 0 && !access.ipAllowed(c.RemoteIP()) {
+		slog.Warn("拒绝不在白名单中的管理接口请求", "remote_ip", c.RemoteIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "IP不在白名单中"})
+		return
+	}
+
+	if access.token != "" {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(access.token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "访问令牌无效"})
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// ipAllowed 判断客户端IP是否在白名单中
+func (a *accessControl) ipAllowed(clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// listAnswers 列出答案记录，可按 status(pending/ready) 和 user 过滤
+func (s *Server) listAnswers(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != "pending" && status != "ready" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status 只能为 pending 或 ready"})
+		return
+	}
+
+	limit := queryLimit(c)
+	answers, err := s.store.ListAnswers(c.Query("user"), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filtered := []*store.Answer{}
+	for _, answer := range answers {
+		if status == "pending" && answer.IsReady || status == "ready" && !answer.IsReady {
+			continue
+		}
+		filtered = append(filtered, answer)
+		if len(filtered) >= limit {
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total": len(filtered), "answers": filtered})
+}
+
+// listUserMessages 列出用户最近的消息及答案
+func (s *Server) listUserMessages(c *gin.Context) {
+	userID := c.Param("user")
+	answers, err := s.store.ListAnswers(userID, queryLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if answers == nil {
+		answers = []*store.Answer{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "total": len(answers), "messages": answers})
+}
+
+// getUserSession 查询用户在各知识库聊天助手下的RAGFlow会话ID
+func (s *Server) getUserSession(c *gin.Context) {
+	userID := c.Param("user")
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "sessions": s.userSessions(userID)})
+}
+
+// clearUserSession 强制清除用户的RAGFlow会话，下次提问时创建新会话
+func (s *Server) clearUserSession(c *gin.Context) {
+	userID := c.Param("user")
+	cleared := s.userSessions(userID)
+	ctx := logging.WithRequestID(c.Request.Context(), "admin")
+	for _, h := range s.handlers {
+		h.ClearUserSessions(ctx, userID)
+	}
+
+	logging.FromContext(ctx).Info("管理接口清除了用户的会话", "user_id", userID, "count", len(cleared))
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "cleared": cleared})
+}
+
+// listAccess 列出黑名单和白名单中的用户
+func (s *Server) listAccess(c *gin.Context) {
+	list, err := s.store.ListUserAccess()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	blocked, allowed := []string{}, []string{}
+	for userID, access := range list {
+		if access == store.AccessBlock {
+			blocked = append(blocked, userID)
+		} else {
+			allowed = append(allowed, userID)
+		}
+	}
+	sort.Strings(blocked)
+	sort.Strings(allowed)
+	c.JSON(http.StatusOK, gin.H{"blocked": blocked, "allowed": allowed})
+}
+
+// setUserAccess 把用户加入黑名单(block)或白名单(allow)，请求体为 {"access": "block"}
+func (s *Server) setUserAccess(c *gin.Context) {
+	userID := c.Param("user")
+	var req struct {
+		Access string `json:"access"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Access != store.AccessBlock && req.Access != store.AccessAllow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access 只能为 block 或 allow"})
+		return
+	}
+
+	if err := s.store.SetUserAccess(userID, req.Access); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logging.FromContext(logging.WithRequestID(c.Request.Context(), "admin")).Info("管理接口修改了用户的黑白名单", "user_id", userID, "access", req.Access)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "access": req.Access})
+}
+
+// deleteUserAccess 把用户移出黑白名单
+func (s *Server) deleteUserAccess(c *gin.Context) {
+	userID := c.Param("user")
+	if err := s.store.DeleteUserAccess(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logging.FromContext(logging.WithRequestID(c.Request.Context(), "admin")).Info("管理接口把用户移出了黑白名单", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "access": ""})
+}
+
+// userSessions 汇总用户在各处理器下的会话，共用存储和聊天助手的处理器返回的同一会话只保留一次
+func (s *Server) userSessions(userID string) []wechat.SessionInfo {
+	sessions := []wechat.SessionInfo{}
+	seen := make(map[string]bool)
+	for _, h := range s.handlers {
+		for _, session := range h.UserSessions(userID) {
+			if !seen[session.SessionID] {
+				seen[session.SessionID] = true
+				sessions = append(sessions, session)
+			}
+		}
+	}
+	return sessions
+}
+
+// queryLimit 读取 limit 参数，未指定或无效时使用默认值
+func queryLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
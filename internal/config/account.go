@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultAccountName 是未配置 accounts 时唯一公众号的名称
+const DefaultAccountName = "default"
+
+// AccountConfig 是一个公众号的完整配置
+type AccountConfig struct {
+	Name       string        // 账号名称，对应回调地址 /wechat/<name>
+	OriginalID string        // 公众号原始ID(gh_开头)，回调地址为 /wechat 时按 ToUserName 路由
+	WeChat     WeChatConfig  // 公众号配置，未填写的字段继承顶层 wechat 配置
+	RagFlow    RagFlowConfig // RAGFlow配置，未填写的字段继承顶层 ragflow 配置
+}
+
+// rawAccounts 是 accounts 段的原始内容，wechat 和 ragflow 保留原始YAML以便覆盖到顶层配置之上
+type rawAccounts struct {
+	Accounts []struct {
+		Name       string        `yaml:"name"`
+		OriginalID string        `yaml:"original_id"`
+		WeChat     yaml.MapSlice `yaml:"wechat"`
+		RagFlow    yaml.MapSlice `yaml:"ragflow"`
+	} `yaml:"accounts"`
+}
+
+// 账号名称用于URL路径，只允许字母、数字、下划线和短横线
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// loadAccounts 解析 accounts 段，每个账号以顶层 wechat、ragflow 配置为基础覆盖自己的字段
+func loadAccounts(data []byte, cfg *Config) error {
+	var raw rawAccounts
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	cfg.Accounts = nil
+	for _, acc := range raw.Accounts {
+		account := AccountConfig{
+			Name:       acc.Name,
+			OriginalID: acc.OriginalID,
+			WeChat:     cfg.WeChat,
+			RagFlow:    cfg.RagFlow,
+		}
+		// map字段会在解析时合并，先复制一份避免修改顶层配置
+		account.WeChat.MenuClicks = copyStringMap(cfg.WeChat.MenuClicks)
+		account.WeChat.SceneActions = copyStringMap(cfg.WeChat.SceneActions)
+
+		if err := overlayYAML(acc.WeChat, &account.WeChat); err != nil {
+			return fmt.Errorf("账号 %s 的 wechat 配置无效: %w", acc.Name, err)
+		}
+		if err := overlayYAML(acc.RagFlow, &account.RagFlow); err != nil {
+			return fmt.Errorf("账号 %s 的 ragflow 配置无效: %w", acc.Name, err)
+		}
+		cfg.Accounts = append(cfg.Accounts, account)
+	}
+	return nil
+}
+
+// overlayYAML 将原始YAML中出现的字段覆盖到 out 上
+func overlayYAML(raw yaml.MapSlice, out interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// copyStringMap 复制字符串map
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// AccountList 返回全部公众号配置，未配置 accounts 时使用顶层配置作为唯一账号
+func (c *Config) AccountList() []AccountConfig {
+	if len(c.Accounts) > 0 {
+		return c.Accounts
+	}
+	return []AccountConfig{{
+		Name:    DefaultAccountName,
+		WeChat:  c.WeChat,
+		RagFlow: c.RagFlow,
+	}}
+}
+
+// FindAccount 按名称查找公众号配置
+func (c *Config) FindAccount(name string) (AccountConfig, bool) {
+	for _, account := range c.AccountList() {
+		if account.Name == name {
+			return account, true
+		}
+	}
+	return AccountConfig{}, false
+}
+
+// ValidateAccounts 检查账号名称、原始ID是否唯一，以及每个账号的检索和知识库配置
+func (c *Config) ValidateAccounts() error {
+	names := make(map[string]bool)
+	originalIDs := make(map[string]bool)
+
+	for i, account := range c.AccountList() {
+		if !accountNamePattern.MatchString(account.Name) {
+			return fmt.Errorf("第%d个账号的 name 无效(只允许字母、数字、下划线和短横线): %q", i+1, account.Name)
+		}
+		if names[account.Name] {
+			return fmt.Errorf("账号名称重复: %s", account.Name)
+		}
+		names[account.Name] = true
+
+		if account.OriginalID != "" {
+			if originalIDs[account.OriginalID] {
+				return fmt.Errorf("账号原始ID重复: %s", account.OriginalID)
+			}
+			originalIDs[account.OriginalID] = true
+		}
+
+		if err := account.RagFlow.Retrieval.Validate(); err != nil {
+			return fmt.Errorf("账号 %s 的知识库检索参数配置无效: %w", account.Name, err)
+		}
+		if err := account.RagFlow.ValidateKnowledgeBases(); err != nil {
+			return fmt.Errorf("账号 %s 的知识库路由配置无效: %w", account.Name, err)
+		}
+	}
+	return nil
+}
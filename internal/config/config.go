@@ -3,27 +3,79 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config 是配置的根结构体
 type Config struct {
-	WeChat  WeChatConfig  `yaml:"wechat"`
-	RagFlow RagFlowConfig `yaml:"ragflow"`
-	Server  ServerConfig  `yaml:"server"`
+	WeChat     WeChatConfig     `yaml:"wechat"`
+	RagFlow    RagFlowConfig    `yaml:"ragflow"`
+	Server     ServerConfig     `yaml:"server"`
+	Store      StoreConfig      `yaml:"store"`
+	Queue      QueueConfig      `yaml:"queue"`
+	WeCom      WeComConfig      `yaml:"wecom"`
+	Admin      AdminConfig      `yaml:"admin"`
+	Log        LogConfig        `yaml:"log"`
+	Limit      LimitConfig      `yaml:"rate_limit"`
+	Moderation ModerationConfig `yaml:"moderation"`
+
+	Accounts []AccountConfig `yaml:"-"` // 多公众号配置，由 accounts 段解析并继承 wechat、ragflow 的配置
 }
 
 // WeChatConfig 包含微信相关配置
 type WeChatConfig struct {
-	AppID     string `yaml:"app_id"`
-	AppSecret string `yaml:"app_secret"`
-	Token     string `yaml:"token"`
-	TokenURL  string `yaml:"token_url"`
+	AppID          string `yaml:"app_id"`
+	AppSecret      string `yaml:"app_secret"`
+	Token          string `yaml:"token"`
+	TokenURL       string `yaml:"token_url"`
+	EncodingAESKey string `yaml:"encoding_aes_key"` // 安全模式/兼容模式下的消息加解密密钥(43位)
+
+	EnableCustomMessage bool   `yaml:"enable_custom_message"` // 超时后是否通过客服消息主动推送答案
+	CustomSendURL       string `yaml:"custom_send_url"`
+	MaxReplyBytes       int    `yaml:"max_reply_bytes"` // 单条文本回复的最大字节数，超出部分分段发送
+
+	WelcomeMessage string            `yaml:"welcome_message"` // 用户关注时发送的欢迎语
+	MenuClicks     map[string]string `yaml:"menu_clicks"`     // 菜单 EventKey -> 预设问题或指令(以/开头)
+	SceneActions   map[string]string `yaml:"scene_actions"`   // 二维码场景值 -> 预设问题或指令(以/开头)
+
+	MediaGetURL string `yaml:"media_get_url"` // 临时素材下载接口
+	ASRURL      string `yaml:"asr_url"`       // 本地语音识别服务地址，微信未返回识别结果时使用
+}
+
+// WeComConfig 包含企业微信自建应用的配置
+type WeComConfig struct {
+	Enabled        bool   `yaml:"enabled"`          // 是否启用 /wecom 回调
+	CorpID         string `yaml:"corp_id"`          // 企业ID
+	CorpSecret     string `yaml:"corp_secret"`      // 自建应用的Secret
+	AgentID        int    `yaml:"agent_id"`         // 自建应用的AgentId
+	Token          string `yaml:"token"`            // 接收消息的Token
+	EncodingAESKey string `yaml:"encoding_aes_key"` // 接收消息的EncodingAESKey(43位)
+	TokenURL       string `yaml:"token_url"`        // 获取 access_token 的接口
+	SendURL        string `yaml:"send_url"`         // 发送应用消息的接口
+	MaxReplyBytes  int    `yaml:"max_reply_bytes"`  // 单条消息的最大字节数，企业微信限制为2048
+}
+
+// Validate 检查启用企业微信回调时必需的配置
+func (w WeComConfig) Validate() error {
+	if !w.Enabled {
+		return nil
+	}
+	if w.CorpID == "" || w.CorpSecret == "" || w.AgentID == 0 {
+		return fmt.Errorf("需要配置 corp_id、corp_secret 和 agent_id")
+	}
+	if w.Token == "" || len(w.EncodingAESKey) != 43 {
+		return fmt.Errorf("需要配置 token 和43位的 encoding_aes_key")
+	}
+	return nil
 }
 
 // RagFlowConfig 包含RAGFlow服务相关配置
@@ -35,89 +87,410 @@ type RagFlowConfig struct {
 	MaxRetries     int    `yaml:"max_retries"`
 	RetryInterval  int    `yaml:"retry_interval"`
 	RequestTimeout int    `yaml:"request_timeout"`
+	Stream         bool   `yaml:"stream"`      // 使用流式接口，首段内容可在微信5秒时限内先行回复
+	AnswerMode     string `yaml:"answer_mode"` // 回答模式: chat、retrieve+llm 或 retrieve-only
+	RedactPII      bool   `yaml:"redact_pii"`  // 发送给RAGFlow的问题中的个人信息替换为占位符，答案中的占位符还原为原文
+
+	Retrieval RetrievalConfig `yaml:"retrieval"` // 知识库检索参数
+
+	DefaultKnowledgeBase string                `yaml:"default_knowledge_base"` // 默认知识库(chat_id/dataset_id)在 /kb 中显示的名称
+	KnowledgeBases       []KnowledgeBaseConfig `yaml:"knowledge_bases"`        // 额外的知识库及其路由规则
+}
+
+// KnowledgeBaseConfig 描述一个知识库及其路由规则，问题命中关键词或正则时使用该知识库
+type KnowledgeBaseConfig struct {
+	Name        string   `yaml:"name"`        // 知识库名称，用于 /kb 切换
+	Description string   `yaml:"description"` // 在 /kb 列表中显示的说明
+	ChatID      string   `yaml:"chat_id"`     // 聊天助手ID，为空时使用 ragflow.chat_id
+	DatasetIDs  []string `yaml:"dataset_ids"` // 检索的知识库ID列表，为空时使用默认检索配置
+	Keywords    []string `yaml:"keywords"`    // 问题包含任一关键词时路由到该知识库
+	Patterns    []string `yaml:"patterns"`    // 问题匹配任一正则时路由到该知识库
+
+	Retrieval RetrievalOverrideConfig `yaml:"retrieval"` // 该知识库的检索参数，未填写的项使用 ragflow.retrieval
+}
+
+// RetrievalOverrideConfig 是单个知识库对检索参数的覆盖，只有填写的项生效，可以填写0或false
+type RetrievalOverrideConfig struct {
+	TopK                   *int     `yaml:"top_k"`
+	SimilarityThreshold    *float64 `yaml:"similarity_threshold"`
+	VectorSimilarityWeight *float64 `yaml:"vector_similarity_weight"`
+	Keyword                *bool    `yaml:"keyword"`
+	RerankID               *string  `yaml:"rerank_id"`
+	Highlight              *bool    `yaml:"highlight"`
+	PageSize               *int     `yaml:"page_size"`
+}
+
+// Validate 按 ragflow.retrieval 的范围检查填写的检索参数
+func (r RetrievalOverrideConfig) Validate() error {
+	var cfg RetrievalConfig
+	if r.TopK != nil {
+		cfg.TopK = *r.TopK
+	}
+	if r.SimilarityThreshold != nil {
+		cfg.SimilarityThreshold = *r.SimilarityThreshold
+	}
+	if r.VectorSimilarityWeight != nil {
+		cfg.VectorSimilarityWeight = *r.VectorSimilarityWeight
+	}
+	if r.PageSize != nil {
+		cfg.PageSize = *r.PageSize
+	}
+	return cfg.Validate()
+}
+
+// ValidateKnowledgeBases 检查知识库名称是否唯一、正则和检索参数是否有效
+func (r RagFlowConfig) ValidateKnowledgeBases() error {
+	names := map[string]bool{r.DefaultKnowledgeBaseName(): true}
+	for i, kb := range r.KnowledgeBases {
+		if kb.Name == "" {
+			return fmt.Errorf("第%d个知识库缺少 name", i+1)
+		}
+		if names[kb.Name] {
+			return fmt.Errorf("知识库名称重复: %s", kb.Name)
+		}
+		names[kb.Name] = true
+
+		for _, pattern := range kb.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("知识库 %s 的正则 %q 无效: %w", kb.Name, pattern, err)
+			}
+		}
+		if err := kb.Retrieval.Validate(); err != nil {
+			return fmt.Errorf("知识库 %s 的 retrieval.%w", kb.Name, err)
+		}
+	}
+	return nil
+}
+
+// DefaultKnowledgeBaseName 返回默认知识库的名称
+func (r RagFlowConfig) DefaultKnowledgeBaseName() string {
+	if r.DefaultKnowledgeBase != "" {
+		return r.DefaultKnowledgeBase
+	}
+	return "默认"
+}
+
+// RetrievalConfig 包含知识库检索接口的调优参数，数值为0时使用RAGFlow的默认值
+type RetrievalConfig struct {
+	DatasetIDs             []string `yaml:"dataset_ids"`              // 检索的知识库ID列表，为空时使用 dataset_id
+	TopK                   int      `yaml:"top_k"`                    // 参与向量相似度计算的片段数
+	SimilarityThreshold    float64  `yaml:"similarity_threshold"`     // 最低相似度(0~1)，低于该值的片段被丢弃
+	VectorSimilarityWeight float64  `yaml:"vector_similarity_weight"` // 向量相似度权重(0~1)，其余为关键词相似度权重
+	Keyword                bool     `yaml:"keyword"`                  // 是否启用关键词匹配
+	RerankID               string   `yaml:"rerank_id"`                // 重排序模型ID
+	Highlight              bool     `yaml:"highlight"`                // 是否高亮匹配的关键词
+	PageSize               int      `yaml:"page_size"`                // 返回的片段数
+}
+
+// Validate 检查检索参数是否在有效范围内
+func (r RetrievalConfig) Validate() error {
+	if r.TopK < 0 {
+		return fmt.Errorf("top_k 不能为负数: %d", r.TopK)
+	}
+	if r.PageSize < 0 {
+		return fmt.Errorf("page_size 不能为负数: %d", r.PageSize)
+	}
+	if r.SimilarityThreshold < 0 || r.SimilarityThreshold > 1 {
+		return fmt.Errorf("similarity_threshold 应在0到1之间: %v", r.SimilarityThreshold)
+	}
+	if r.VectorSimilarityWeight < 0 || r.VectorSimilarityWeight > 1 {
+		return fmt.Errorf("vector_similarity_weight 应在0到1之间: %v", r.VectorSimilarityWeight)
+	}
+	for _, id := range r.DatasetIDs {
+		if id == "" {
+			return fmt.Errorf("dataset_ids 中不能有空的知识库ID")
+		}
+	}
+	return nil
 }
 
 // ServerConfig 包含服务器相关配置
 type ServerConfig struct {
-	Port int `yaml:"port"`
+	Port                int    `yaml:"port"`
+	ConfigWatchInterval int    `yaml:"config_watch_interval"` // 检查配置文件是否修改的间隔(秒)，0表示只在收到 SIGHUP 时重新加载
+	ShutdownTimeout     int    `yaml:"shutdown_timeout"`      // 停止服务时等待处理中答案的最长时间(秒)
+	PendingFile         string `yaml:"pending_file"`          // 停止时仍未完成的问题保存到该文件，下次启动时读取
+	ResumePending       bool   `yaml:"resume_pending"`        // 启动时是否重新处理上次未完成的问题，否则告知用户重新发送
+}
+
+// StoreConfig 包含答案、消息排重记录和会话映射的存储配置
+type StoreConfig struct {
+	Type            string `yaml:"type"`             // memory(默认) 或 bolt
+	Path            string `yaml:"path"`             // bolt 数据文件路径
+	AnswerTTL       int    `yaml:"answer_ttl"`       // 答案和消息排重记录保留时间(秒)
+	SessionTTL      int    `yaml:"session_ttl"`      // 用户会话映射保留时间(秒)
+	CleanupInterval int    `yaml:"cleanup_interval"` // 过期数据清理间隔(秒)
+}
+
+// QueueConfig 包含RAG请求任务队列配置
+type QueueConfig struct {
+	Workers int `yaml:"workers"` // 同时处理问题的工作协程数
+	Size    int `yaml:"size"`    // 最多排队的问题数，超出时回复系统繁忙
+}
+
+// LogConfig 包含日志输出配置
+type LogConfig struct {
+	Level     string `yaml:"level"`      // 日志级别: debug、info(默认)、warn、error
+	Format    string `yaml:"format"`     // 输出格式: json(默认) 或 text
+	LogBodies bool   `yaml:"log_bodies"` // 是否记录用户问题、答案和RAGFlow请求/响应体，关闭时只记录长度
+	MaskPII   bool   `yaml:"mask_pii"`   // 是否屏蔽日志中的手机号、身份证号、银行卡号和邮箱
+}
+
+// LimitConfig 包含按用户(OpenID)限制提问的配置，只对需要调用RAGFlow的提问计数，指令消息不受限制
+// 黑名单和白名单通过管理接口维护，不受 enabled 影响
+type LimitConfig struct {
+	Enabled        bool   `yaml:"enabled"`         // 是否限制提问频率和每日次数
+	PerMinute      int    `yaml:"per_minute"`      // 每分钟恢复的提问次数(令牌桶的速率)，0表示不限制频率
+	Burst          int    `yaml:"burst"`           // 短时间内最多连续提问的次数(令牌桶的容量)
+	DailyQuota     int    `yaml:"daily_quota"`     // 每个用户每天最多提问的次数，按服务器时区0点重置，0表示不限制
+	BlockedMessage string `yaml:"blocked_message"` // 回复黑名单用户的消息，为空时不回复
+}
+
+// Validate 检查限制次数不为负数，限制频率时需要配置连续提问次数
+func (l LimitConfig) Validate() error {
+	if l.PerMinute < 0 || l.Burst < 0 || l.DailyQuota < 0 {
+		return fmt.Errorf("per_minute、burst 和 daily_quota 不能为负数")
+	}
+	if l.Enabled && l.PerMinute > 0 && l.Burst == 0 {
+		return fmt.Errorf("限制提问频率时 burst 至少为1")
+	}
+	return nil
+}
+
+// ModerationConfig 包含问题和答案的内容审核配置，敏感词表和审核接口可以同时使用
+type ModerationConfig struct {
+	Enabled        bool     `yaml:"enabled"`         // 是否审核用户的问题和RAGFlow的答案
+	Words          []string `yaml:"words"`           // 敏感词，英文不区分大小写
+	WordFile       string   `yaml:"word_file"`       // 敏感词文件，每行一个词，# 开头的行为注释
+	QuestionAction string   `yaml:"question_action"` // 问题命中时的处理: block(不回复)、mask(屏蔽敏感词后继续回答)、replace(回复 replace_message)
+	AnswerAction   string   `yaml:"answer_action"`   // 答案命中时的处理: mask 或 replace
+	ReplaceMessage string   `yaml:"replace_message"` // replace 时回复的消息
+	MaskChar       string   `yaml:"mask_char"`       // mask 时替换敏感词每个字符的字符
+	HookURL        string   `yaml:"hook_url"`        // 本地审核接口地址(可选)，见 README
+	HookTimeout    int      `yaml:"hook_timeout"`    // 审核接口超时时间(秒)，超时或失败时只使用敏感词表
+	AuditFile      string   `yaml:"audit_file"`      // 审核命中记录文件(JSON Lines)，为空时只写入日志
+}
+
+// Validate 检查处理方式、审核接口地址和敏感词文件
+func (m ModerationConfig) Validate() error {
+	if !m.Enabled {
+		return nil
+	}
+	switch m.QuestionAction {
+	case "block", "mask", "replace":
+	default:
+		return fmt.Errorf("question_action 只能为 block、mask 或 replace: %q", m.QuestionAction)
+	}
+	switch m.AnswerAction {
+	case "mask", "replace":
+	default:
+		return fmt.Errorf("answer_action 只能为 mask 或 replace: %q", m.AnswerAction)
+	}
+	if utf8.RuneCountInString(m.MaskChar) != 1 {
+		return fmt.Errorf("mask_char 应为一个字符: %q", m.MaskChar)
+	}
+	if m.HookURL != "" && !strings.HasPrefix(m.HookURL, "http://") && !strings.HasPrefix(m.HookURL, "https://") {
+		return fmt.Errorf("hook_url 应以 http:// 或 https:// 开头: %q", m.HookURL)
+	}
+	if m.WordFile != "" {
+		if _, err := os.Stat(m.WordFile); err != nil {
+			return fmt.Errorf("无法读取 word_file: %w", err)
+		}
+	}
+	if len(m.Words) == 0 && m.WordFile == "" && m.HookURL == "" {
+		return fmt.Errorf("启用时需要配置 words、word_file 或 hook_url")
+	}
+	return nil
+}
+
+// AdminConfig 包含管理接口的访问控制配置
+type AdminConfig struct {
+	Enabled  bool     `yaml:"enabled"`   // 是否启用 /admin 管理接口
+	Token    string   `yaml:"token"`     // 访问令牌，请求需携带 Authorization: Bearer <token>
+	AllowIPs []string `yaml:"allow_ips"` // 允许访问的IP或网段(CIDR)
+}
+
+// Validate 检查启用管理接口时至少配置了令牌或IP白名单，且白名单格式正确
+func (a AdminConfig) Validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.Token == "" && len(a.AllowIPs) == 0 {
+		return fmt.Errorf("需要配置 token 或 allow_ips")
+	}
+	for _, ip := range a.AllowIPs {
+		if net.ParseIP(ip) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			return fmt.Errorf("allow_ips 中的 %q 不是有效的IP或网段", ip)
+		}
+	}
+	return nil
 }
 
 var (
 	config     *Config
-	configOnce sync.Once
+	configFile string // 加载配置时实际读取的文件，未找到配置文件时为空
+	configMu   sync.Mutex
 )
 
-// GetConfig 返回配置单例
+// Load 加载配置并作为 GetConfig 返回的配置。path 为空时依次查找默认位置，
+// 找不到配置文件时只使用默认值和环境变量；指定的文件不存在、无法解析或环境变量无效时返回错误
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	file, err := loadConfig(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+
+	configMu.Lock()
+	config = cfg
+	configFile = file
+	configMu.Unlock()
+	return cfg, nil
+}
+
+// GetConfig 返回已加载的配置，尚未加载时从默认位置加载，加载失败时panic
 func GetConfig() *Config {
-	configOnce.Do(func() {
-		config = &Config{}
-		err := loadConfig(config)
-		if err != nil {
-			log.Printf("加载配置文件失败: %v，将使用默认值\n", err)
-			setDefaultConfig(config)
-		}
-	})
+	configMu.Lock()
+	cfg := config
+	configMu.Unlock()
+	if cfg != nil {
+		return cfg
+	}
 
-	return config
+	cfg, err := Load("")
+	if err != nil {
+		panic(fmt.Sprintf("加载配置失败: %v", err))
+	}
+	return cfg
 }
 
-// loadConfig 从配置文件加载配置
-func loadConfig(cfg *Config) error {
+// loadConfig 以默认值为基础，依次覆盖配置文件和环境变量中的配置，返回实际读取的配置文件路径
+func loadConfig(cfg *Config, path string) (string, error) {
+	setDefaultConfig(cfg)
+
+	configData, file, err := readConfigFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	// 解析YAML配置，未填写的字段保留默认值
+	if err := yaml.Unmarshal(configData, cfg); err != nil {
+		return "", fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	// 环境变量在账号继承之前覆盖，未单独配置的账号同样生效
+	if err := applyEnvOverrides(cfg); err != nil {
+		return "", err
+	}
+
+	if err := loadAccounts(configData, cfg); err != nil {
+		return "", fmt.Errorf("解析公众号账号配置失败: %w", err)
+	}
+
+	return file, nil
+}
+
+// readConfigFile 读取配置文件，未指定路径时从多个位置查找，都不存在时返回空内容
+func readConfigFile(path string) ([]byte, string, error) {
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		slog.Info("加载配置文件", "path", path)
+		return data, path, nil
+	}
+
 	// 尝试从多个位置查找配置文件
 	configPaths := []string{
 		"config.yml",    // 当前目录
 		"../config.yml", // 上级目录
 		filepath.Join(os.Getenv("HOME"), "config.yml"), // 用户主目录
 	}
-
-	var configData []byte
-	var err error
-
-	// 尝试从各个路径读取配置
 	for _, path := range configPaths {
-		configData, err = ioutil.ReadFile(path)
+		data, err := ioutil.ReadFile(path)
 		if err == nil {
-			log.Printf("从 %s 加载配置\n", path)
-			break
+			slog.Info("加载配置文件", "path", path)
+			return data, path, nil
 		}
 	}
 
-	if err != nil {
-		return fmt.Errorf("无法找到配置文件: %w", err)
-	}
-
-	// 解析YAML配置
-	err = yaml.Unmarshal(configData, cfg)
-	if err != nil {
-		return fmt.Errorf("解析配置文件失败: %w", err)
-	}
-
-	return nil
+	slog.Warn("未找到配置文件，仅使用默认值和环境变量")
+	return nil, "", nil
 }
 
-// setDefaultConfig 设置默认配置
+// setDefaultConfig 设置默认配置，AppSecret、API Key 等账号信息没有默认值，需在配置文件或环境变量中提供
 func setDefaultConfig(cfg *Config) {
 	// 默认微信配置
 	cfg.WeChat = WeChatConfig{
-		AppID:     "wx39fc841a05350758",
-		AppSecret: "8280c222717449b5147b5cd9db7bbcda",
-		Token:     "wechat_rag_token",
-		TokenURL:  "https://api.weixin.qq.com/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s",
+		TokenURL: "https://api.weixin.qq.com/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s",
+
+		EnableCustomMessage: false,
+		CustomSendURL:       "https://api.weixin.qq.com/cgi-bin/message/custom/send?access_token=%s",
+		MaxReplyBytes:       2000,
 	}
 
 	// 默认RAGFlow配置
 	cfg.RagFlow = RagFlowConfig{
-		BaseURL:        "http://ragflow-server",
-		ApiKey:         "ragflow-cwNWNkZGFhMzMxNzExZjA5MmM5MDI0Mj",
-		ChatID:         "5e48a6dc331a11f0af0302420aff0606",
-		DatasetID:      "7b214898331711f09ded02420aff0606",
 		MaxRetries:     2,
 		RetryInterval:  1,
 		RequestTimeout: 120,
+		AnswerMode:     "chat",
+		Retrieval: RetrievalConfig{
+			TopK:                   1024,
+			SimilarityThreshold:    0.2,
+			VectorSimilarityWeight: 0.3,
+			PageSize:               5,
+		},
 	}
 
 	// 默认服务器配置
 	cfg.Server = ServerConfig{
-		Port: 80,
+		Port:                80,
+		ConfigWatchInterval: 5,
+		ShutdownTimeout:     8,
+		PendingFile:         "data/pending_answers.json",
+		ResumePending:       true,
+	}
+
+	// 默认存储配置
+	cfg.Store = StoreConfig{
+		Type:            "memory",
+		AnswerTTL:       86400,
+		SessionTTL:      604800,
+		CleanupInterval: 600,
+	}
+
+	// 默认任务队列配置
+	cfg.Queue = QueueConfig{
+		Workers: 4,
+		Size:    100,
+	}
+
+	// 默认日志配置
+	cfg.Log = LogConfig{
+		Level:   "info",
+		Format:  "json",
+		MaskPII: true,
+	}
+
+	// 默认内容审核配置
+	cfg.Moderation = ModerationConfig{
+		QuestionAction: "replace",
+		AnswerAction:   "replace",
+		ReplaceMessage: "抱歉，这个问题暂时无法回答。",
+		MaskChar:       "*",
+		HookTimeout:    2,
+		AuditFile:      "data/moderation_audit.log",
+	}
+
+	// 默认提问限制，启用后每分钟恢复6次、最多连续提问3次、每天100次
+	cfg.Limit = LimitConfig{
+		PerMinute:  6,
+		Burst:      3,
+		DailyQuota: 100,
 	}
 }
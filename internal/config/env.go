@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envOverride 是一个可由环境变量覆盖的配置项
+type envOverride struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envOverrides 列出支持的环境变量，容器部署时可通过它们注入密钥而不写入配置文件
+// 未配置 accounts 或账号未单独填写时，各账号继承这里覆盖后的 wechat、ragflow 配置
+var envOverrides = []envOverride{
+	envString("WECHAT_APP_ID", func(c *Config) *string { return &c.WeChat.AppID }),
+	envString("WECHAT_APP_SECRET", func(c *Config) *string { return &c.WeChat.AppSecret }),
+	envString("WECHAT_TOKEN", func(c *Config) *string { return &c.WeChat.Token }),
+	envString("WECHAT_ENCODING_AES_KEY", func(c *Config) *string { return &c.WeChat.EncodingAESKey }),
+	envBool("WECHAT_ENABLE_CUSTOM_MESSAGE", func(c *Config) *bool { return &c.WeChat.EnableCustomMessage }),
+
+	envString("RAGFLOW_BASE_URL", func(c *Config) *string { return &c.RagFlow.BaseURL }),
+	envString("RAGFLOW_API_KEY", func(c *Config) *string { return &c.RagFlow.ApiKey }),
+	envString("RAGFLOW_CHAT_ID", func(c *Config) *string { return &c.RagFlow.ChatID }),
+	envString("RAGFLOW_DATASET_ID", func(c *Config) *string { return &c.RagFlow.DatasetID }),
+	envString("RAGFLOW_ANSWER_MODE", func(c *Config) *string { return &c.RagFlow.AnswerMode }),
+	envBool("RAGFLOW_STREAM", func(c *Config) *bool { return &c.RagFlow.Stream }),
+	envBool("RAGFLOW_REDACT_PII", func(c *Config) *bool { return &c.RagFlow.RedactPII }),
+
+	envInt("SERVER_PORT", func(c *Config) *int { return &c.Server.Port }),
+	envInt("SERVER_SHUTDOWN_TIMEOUT", func(c *Config) *int { return &c.Server.ShutdownTimeout }),
+
+	envString("STORE_TYPE", func(c *Config) *string { return &c.Store.Type }),
+	envString("STORE_PATH", func(c *Config) *string { return &c.Store.Path }),
+
+	envInt("QUEUE_WORKERS", func(c *Config) *int { return &c.Queue.Workers }),
+	envInt("QUEUE_SIZE", func(c *Config) *int { return &c.Queue.Size }),
+
+	envBool("WECOM_ENABLED", func(c *Config) *bool { return &c.WeCom.Enabled }),
+	envString("WECOM_CORP_ID", func(c *Config) *string { return &c.WeCom.CorpID }),
+	envString("WECOM_CORP_SECRET", func(c *Config) *string { return &c.WeCom.CorpSecret }),
+	envInt("WECOM_AGENT_ID", func(c *Config) *int { return &c.WeCom.AgentID }),
+	envString("WECOM_TOKEN", func(c *Config) *string { return &c.WeCom.Token }),
+	envString("WECOM_ENCODING_AES_KEY", func(c *Config) *string { return &c.WeCom.EncodingAESKey }),
+
+	envBool("ADMIN_ENABLED", func(c *Config) *bool { return &c.Admin.Enabled }),
+	envString("ADMIN_TOKEN", func(c *Config) *string { return &c.Admin.Token }),
+
+	envBool("RATE_LIMIT_ENABLED", func(c *Config) *bool { return &c.Limit.Enabled }),
+	envInt("RATE_LIMIT_PER_MINUTE", func(c *Config) *int { return &c.Limit.PerMinute }),
+	envInt("RATE_LIMIT_BURST", func(c *Config) *int { return &c.Limit.Burst }),
+	envInt("RATE_LIMIT_DAILY_QUOTA", func(c *Config) *int { return &c.Limit.DailyQuota }),
+
+	envString("LOG_LEVEL", func(c *Config) *string { return &c.Log.Level }),
+	envString("LOG_FORMAT", func(c *Config) *string { return &c.Log.Format }),
+	envBool("LOG_BODIES", func(c *Config) *bool { return &c.Log.LogBodies }),
+	envBool("LOG_MASK_PII", func(c *Config) *bool { return &c.Log.MaskPII }),
+}
+
+func envString(name string, field func(*Config) *string) envOverride {
+	return envOverride{name: name, apply: func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}}
+}
+
+func envInt(name string, field func(*Config) *int) envOverride {
+	return envOverride{name: name, apply: func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 应为整数: %q", name, value)
+		}
+		*field(cfg) = n
+		return nil
+	}}
+}
+
+func envBool(name string, field func(*Config) *bool) envOverride {
+	return envOverride{name: name, apply: func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 应为 true 或 false: %q", name, value)
+		}
+		*field(cfg) = b
+		return nil
+	}}
+}
+
+// applyEnvOverrides 用环境变量覆盖配置文件中的值，未设置或为空的环境变量不覆盖
+func applyEnvOverrides(cfg *Config) error {
+	for _, override := range envOverrides {
+		value := os.Getenv(override.name)
+		if value == "" {
+			continue
+		}
+		if err := override.apply(cfg, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
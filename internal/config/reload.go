@@ -0,0 +1,84 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Reload 重新读取启动时加载的配置文件和环境变量，校验通过后替换 GetConfig 返回的配置；
+// 校验失败时返回错误，当前配置保持不变
+func Reload() (*Config, error) {
+	configMu.Lock()
+	file := configFile
+	configMu.Unlock()
+
+	cfg := &Config{}
+	if _, err := loadConfig(cfg, file); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	configMu.Lock()
+	config = cfg
+	configMu.Unlock()
+	return cfg, nil
+}
+
+// Watch 在收到 SIGHUP 或配置文件修改后重新加载配置，成功时以新配置调用 onReload。
+// interval 为检查文件修改时间的间隔，为0时只响应 SIGHUP
+func Watch(interval time.Duration, onReload func(cfg *Config)) {
+	configMu.Lock()
+	file := configFile
+	configMu.Unlock()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 && file != "" {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+	}
+	lastMod := fileModTime(file)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				slog.Info("收到 SIGHUP，重新加载配置")
+			case <-tick:
+				mod := fileModTime(file)
+				if mod.Equal(lastMod) {
+					continue
+				}
+				lastMod = mod
+				slog.Info("配置文件已修改，重新加载配置", "path", file)
+			}
+
+			cfg, err := Reload()
+			if err != nil {
+				slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+				continue
+			}
+			onReload(cfg)
+			slog.Info("配置已重新加载")
+		}
+	}()
+}
+
+// fileModTime 返回文件的修改时间，文件不存在时返回零值
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate 检查启动服务所需的全部配置，返回所有问题而不是只返回第一个，便于一次修正
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(section string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", section, err))
+		}
+	}
+
+	add("accounts", c.ValidateAccounts())
+	for _, account := range c.AccountList() {
+		prefix := "账号 " + account.Name
+		for _, err := range account.WeChat.validateRequired() {
+			add(prefix, err)
+		}
+		add(prefix, account.WeChat.validateLimits())
+		for _, err := range account.RagFlow.validateRequired() {
+			add(prefix, err)
+		}
+		for _, err := range account.RagFlow.validateLimits() {
+			add(prefix, err)
+		}
+	}
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		add("server", fmt.Errorf("port 无效: %d (环境变量 SERVER_PORT)", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		add("server", fmt.Errorf("shutdown_timeout 不能为负数: %d", c.Server.ShutdownTimeout))
+	}
+	add("store", c.Store.Validate())
+	if c.Queue.Workers < 0 || c.Queue.Size < 0 {
+		add("queue", fmt.Errorf("workers 和 size 不能为负数"))
+	}
+	add("log", c.Log.Validate())
+	add("rate_limit", c.Limit.Validate())
+	add("moderation", c.Moderation.Validate())
+	add("wecom", c.WeCom.Validate())
+	add("admin", c.Admin.Validate())
+
+	return errors.Join(errs...)
+}
+
+// validateRequired 检查公众号的必填项
+func (w WeChatConfig) validateRequired() []error {
+	var errs []error
+	if w.AppID == "" {
+		errs = append(errs, errors.New("wechat.app_id 未配置 (环境变量 WECHAT_APP_ID)"))
+	}
+	if w.AppSecret == "" {
+		errs = append(errs, errors.New("wechat.app_secret 未配置 (环境变量 WECHAT_APP_SECRET)"))
+	}
+	if w.Token == "" {
+		errs = append(errs, errors.New("wechat.token 未配置 (环境变量 WECHAT_TOKEN)"))
+	}
+	if w.EncodingAESKey != "" && len(w.EncodingAESKey) != 43 {
+		errs = append(errs, fmt.Errorf("wechat.encoding_aes_key 应为43位，当前为%d位", len(w.EncodingAESKey)))
+	}
+	return errs
+}
+
+// 单条回复中为“回复 /more”等提示语预留的字节数(与 wechat 包一致)和微信文本消息的长度上限
+const (
+	replyHintReserveBytes = 200
+	maxWeChatReplyBytes   = 2048
+)
+
+// validateLimits 检查单条回复的长度，0表示使用默认值
+func (w WeChatConfig) validateLimits() error {
+	if w.MaxReplyBytes != 0 && (w.MaxReplyBytes <= replyHintReserveBytes || w.MaxReplyBytes > maxWeChatReplyBytes) {
+		return fmt.Errorf("wechat.max_reply_bytes 应大于%d且不超过%d: %d", replyHintReserveBytes, maxWeChatReplyBytes, w.MaxReplyBytes)
+	}
+	return nil
+}
+
+// validateLimits 检查RAGFlow请求的超时和重试配置
+func (r RagFlowConfig) validateLimits() []error {
+	var errs []error
+	if r.RequestTimeout < 1 || r.RequestTimeout > 600 {
+		errs = append(errs, fmt.Errorf("ragflow.request_timeout 应在1到600秒之间: %d", r.RequestTimeout))
+	}
+	if r.MaxRetries < 0 || r.MaxRetries > 10 {
+		errs = append(errs, fmt.Errorf("ragflow.max_retries 应在0到10之间: %d", r.MaxRetries))
+	}
+	if r.RetryInterval < 0 || r.RetryInterval > 60 {
+		errs = append(errs, fmt.Errorf("ragflow.retry_interval 应在0到60秒之间: %d", r.RetryInterval))
+	}
+	return errs
+}
+
+// validateRequired 检查RAGFlow的必填项，聊天助手ID和知识库ID按回答模式要求
+func (r RagFlowConfig) validateRequired() []error {
+	var errs []error
+	if r.BaseURL == "" {
+		errs = append(errs, errors.New("ragflow.base_url 未配置 (环境变量 RAGFLOW_BASE_URL)"))
+	} else if !strings.HasPrefix(r.BaseURL, "http://") && !strings.HasPrefix(r.BaseURL, "https://") {
+		errs = append(errs, fmt.Errorf("ragflow.base_url 应以 http:// 或 https:// 开头: %q", r.BaseURL))
+	}
+	if r.ApiKey == "" {
+		errs = append(errs, errors.New("ragflow.api_key 未配置 (环境变量 RAGFLOW_API_KEY)"))
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(r.AnswerMode))
+	switch mode {
+	case "", "chat", "retrieve+llm", "retrieve-only":
+	default:
+		errs = append(errs, fmt.Errorf("ragflow.answer_mode 只能为 chat、retrieve+llm 或 retrieve-only: %q", r.AnswerMode))
+	}
+	// 仅返回检索摘录时不调用聊天助手
+	if r.ChatID == "" && mode != "retrieve-only" {
+		errs = append(errs, errors.New("ragflow.chat_id 未配置 (环境变量 RAGFLOW_CHAT_ID)"))
+	}
+	if (mode == "retrieve+llm" || mode == "retrieve-only") && r.DatasetID == "" && len(r.Retrieval.DatasetIDs) == 0 {
+		errs = append(errs, errors.New("检索模式需要配置 ragflow.dataset_id 或 ragflow.retrieval.dataset_ids (环境变量 RAGFLOW_DATASET_ID)"))
+	}
+	return errs
+}
+
+// Validate 检查存储类型和 bolt 数据文件路径
+func (s StoreConfig) Validate() error {
+	switch s.Type {
+	case "", "memory":
+		return nil
+	case "bolt":
+		if s.Path == "" {
+			return errors.New("bolt 存储需要配置 path (环境变量 STORE_PATH)")
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的存储类型: %q", s.Type)
+	}
+}
+
+// Validate 检查日志级别和输出格式
+func (l LogConfig) Validate() error {
+	switch strings.ToLower(l.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return fmt.Errorf("level 只能为 debug、info、warn 或 error: %q", l.Level)
+	}
+	switch strings.ToLower(l.Format) {
+	case "", "json", "text":
+	default:
+		return fmt.Errorf("format 只能为 json 或 text: %q", l.Format)
+	}
+	return nil
+}
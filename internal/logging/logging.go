@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/redact"
+)
+
+var (
+	// 是否记录用户问题、答案和请求/响应体
+	logBodies atomic.Bool
+	// 是否屏蔽日志中的个人信息
+	maskPII atomic.Bool
+	// 当前日志级别，配置热更新后已创建的日志记录器同样生效
+	level slog.LevelVar
+)
+
+// Setup 根据配置设置全局的 slog 日志输出，可重复调用以应用新的配置
+func Setup(cfg config.LogConfig) {
+	level.Set(ParseLevel(cfg.Level))
+	opts := &slog.HandlerOptions{Level: &level, ReplaceAttr: maskAttr}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	logBodies.Store(cfg.LogBodies)
+	maskPII.Store(cfg.MaskPII)
+}
+
+// maskAttr 屏蔽日志字段(含错误信息)中的手机号、身份证号、银行卡号和邮箱
+func maskAttr(_ []string, a slog.Attr) slog.Attr {
+	if !maskPII.Load() {
+		return a
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redact.Mask(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redact.Mask(err.Error()))
+		}
+	}
+	return a
+}
+
+// MaskPII 在开启 mask_pii 时屏蔽文本中的个人信息，用于日志之外的记录(如审核记录)
+func MaskPII(text string) string {
+	if !maskPII.Load() {
+		return text
+	}
+	return redact.Mask(text)
+}
+
+// ParseLevel 解析日志级别，无法识别时使用 info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type (
+	loggerKey    struct{}
+	requestIDKey struct{}
+)
+
+// WithRequestID 返回携带关联ID的context，之后通过 FromContext 取得的日志都带有 request_id 字段
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).With("request_id", requestID))
+}
+
+// RequestID 返回context中携带的关联ID，没有时返回空
+func RequestID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// NewContext 为一条消息创建独立于HTTP请求的context，异步任务在回调返回后仍可使用
+func NewContext(requestID string) context.Context {
+	return WithRequestID(context.Background(), requestID)
+}
+
+// FromContext 返回context中携带的日志记录器，没有时返回全局记录器
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// Body 返回记录文本内容的字段；未开启 log_bodies 时只记录字节数，避免用户内容和接口响应写入日志
+func Body(key, value string) slog.Attr {
+	if logBodies.Load() {
+		return slog.String(key, value)
+	}
+	return slog.Int(key+"_bytes", len(value))
+}
+
+// Middleware 以结构化日志记录每个HTTP请求，替代 gin 默认的文本访问日志
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(c.Request.Context(), level, "HTTP请求",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 服务使用的全部指标
+var (
+	// MessagesReceived 按账号和消息类型统计收到的消息
+	MessagesReceived = NewCounterVec("wechat_messages_received_total",
+		"收到的消息数，按账号和MsgType区分", "account", "msg_type")
+
+	// PassiveReplies 统计提问在被动回复时限内的结果: answered 直接回复答案，partial 先行回复首段，timeout 超时回复处理中
+	PassiveReplies = NewCounterVec("wechat_passive_replies_total",
+		"提问的被动回复结果(answered/partial/timeout)", "account", "result")
+
+	// RateLimited 统计因超出频率(rate)、每日次数(daily)限制或在黑名单中(blocked)而未处理的提问
+	RateLimited = NewCounterVec("wechat_rate_limited_total",
+		"未处理的提问数，按账号和原因(rate/daily/blocked)区分", "account", "reason")
+
+	// ModerationHits 统计内容审核命中的次数
+	ModerationHits = NewCounterVec("moderation_hits_total",
+		"内容审核命中次数，按审核内容(question/answer)和处理方式(mask/replace/block)区分", "stage", "action")
+
+	// RagflowRequestDuration 统计RAGFlow各接口的请求耗时(含重试)
+	RagflowRequestDuration = NewHistogramVec("ragflow_request_duration_seconds",
+		"RAGFlow接口请求耗时(秒)，包含重试", nil, "endpoint")
+
+	// RagflowRetries 统计RAGFlow请求的重试次数
+	RagflowRetries = NewCounterVec("ragflow_request_retries_total",
+		"RAGFlow接口请求的重试次数", "endpoint")
+
+	// RagflowErrors 统计RAGFlow请求失败次数(所有重试都失败)
+	RagflowErrors = NewCounterVec("ragflow_request_errors_total",
+		"RAGFlow接口请求失败次数(重试后仍失败)", "endpoint")
+
+	// TokenRefreshes 统计 access_token 的刷新结果
+	TokenRefreshes = NewCounterVec("access_token_refreshes_total",
+		"access_token 刷新次数，按应用和结果(success/failure)区分", "app", "result")
+
+	// CacheSize 是各缓存、队列当前的条目数，取值函数在启动时注册
+	CacheSize = NewGaugeFuncVec("cache_entries",
+		"缓存和队列中的条目数", "cache")
+)
+
+// Handler 是输出 Prometheus 文本格式指标的 /metrics 接口
+func Handler(c *gin.Context) {
+	var buf bytes.Buffer
+	defaultRegistry.Write(&buf)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
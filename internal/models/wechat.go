@@ -8,6 +8,19 @@ type WeChatMessage struct {
 	MsgType      string `xml:"MsgType"`
 	Content      string `xml:"Content"`
 	MsgId        string `xml:"MsgId"` // 添加消息ID字段，用于处理重试
+
+	// 语音消息字段
+	MediaId     string `xml:"MediaId"`     // 语音消息媒体ID，可通过临时素材接口下载
+	Format      string `xml:"Format"`      // 语音格式，如 amr、speex
+	Recognition string `xml:"Recognition"` // 微信语音识别结果(需开通接收语音识别结果)
+
+	// 事件推送字段
+	Event    string `xml:"Event"`    // 事件类型: subscribe、unsubscribe、CLICK、VIEW、SCAN
+	EventKey string `xml:"EventKey"` // 菜单KEY、跳转URL或二维码场景值
+	Ticket   string `xml:"Ticket"`   // 二维码的ticket
+
+	// 企业微信字段
+	AgentID int `xml:"AgentID"` // 接收消息的自建应用ID
 }
 
 // WeChatResponse 是返回给微信的消息结构体
@@ -0,0 +1,99 @@
+package moderation
+
+import "unicode"
+
+// matcher 是由敏感词构建的 Aho-Corasick 自动机，一次扫描即可找出文本中的全部敏感词，英文字母不区分大小写
+type matcher struct {
+	nodes []acNode
+}
+
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // 以该节点结尾的敏感词长度(字符数)
+}
+
+// match 是文本中一处敏感词的位置，按字符(rune)计
+type match struct {
+	start, end int
+}
+
+// newMatcher 构建自动机，空词被忽略
+func newMatcher(words []string) *matcher {
+	m := &matcher{nodes: []acNode{{next: map[rune]int{}}}}
+	for _, word := range words {
+		runes := []rune(word)
+		if len(runes) == 0 {
+			continue
+		}
+
+		cur := 0
+		for _, r := range runes {
+			r = unicode.ToLower(r)
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				m.nodes = append(m.nodes, acNode{next: map[rune]int{}})
+				nxt = len(m.nodes) - 1
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		m.nodes[cur].output = append(m.nodes[cur].output, len(runes))
+	}
+
+	// 按层次遍历计算失败指针，并合并失败链上的输出
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for fail > 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// empty 返回是否没有任何敏感词
+func (m *matcher) empty() bool {
+	return m == nil || len(m.nodes) <= 1
+}
+
+// find 返回文本中全部敏感词的位置，text 为按字符拆分的文本
+func (m *matcher) find(text []rune) []match {
+	if m.empty() {
+		return nil
+	}
+
+	var matches []match
+	cur := 0
+	for i, r := range text {
+		r = unicode.ToLower(r)
+		for cur > 0 {
+			if _, ok := m.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		if nxt, ok := m.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+		for _, length := range m.nodes[cur].output {
+			matches = append(matches, match{start: i + 1 - length, end: i + 1})
+		}
+	}
+	return matches
+}
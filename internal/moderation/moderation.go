@@ -0,0 +1,325 @@
+package moderation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+)
+
+// 审核的内容
+const (
+	StageQuestion = "question" // 用户的问题
+	StageAnswer   = "answer"   // RAGFlow返回的答案
+)
+
+// 命中后的处理方式，按严格程度递增
+const (
+	ActionPass    = "pass"    // 未命中
+	ActionMask    = "mask"    // 用 mask_char 屏蔽敏感词
+	ActionReplace = "replace" // 整体替换为 replace_message
+	ActionBlock   = "block"   // 不回复，仅用于问题
+)
+
+var actionLevel = map[string]int{ActionPass: 0, ActionMask: 1, ActionReplace: 2, ActionBlock: 3}
+
+// Result 是一次审核的结果
+type Result struct {
+	Action string   // 处理方式，见 Action*
+	Text   string   // 处理后的文本：pass 时为原文，block 时为空
+	Words  []string // 命中的敏感词
+	Source string   // 命中来源: words(敏感词表)、hook(审核接口)或 words+hook
+}
+
+// Moderator 审核用户的问题和RAGFlow的答案，命中时按配置屏蔽或替换，并写入审核记录
+type Moderator struct {
+	state atomic.Pointer[moderatorState]
+
+	auditMu sync.Mutex
+}
+
+// moderatorState 是配置及由其构建的自动机，配置热更新时整体替换
+type moderatorState struct {
+	cfg     config.ModerationConfig
+	matcher *matcher
+	client  *http.Client
+}
+
+// New 按配置创建审核器，读取敏感词文件失败时返回错误
+func New(cfg config.ModerationConfig) (*Moderator, error) {
+	m := &Moderator{}
+	if err := m.UpdateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateConfig 重新读取敏感词并替换配置，失败时保留原配置
+func (m *Moderator) UpdateConfig(cfg config.ModerationConfig) error {
+	words := append([]string(nil), cfg.Words...)
+	if cfg.Enabled && cfg.WordFile != "" {
+		fileWords, err := loadWordFile(cfg.WordFile)
+		if err != nil {
+			return err
+		}
+		words = append(words, fileWords...)
+	}
+
+	m.state.Store(&moderatorState{
+		cfg:     cfg,
+		matcher: newMatcher(words),
+		client:  &http.Client{Timeout: time.Duration(cfg.HookTimeout) * time.Second},
+	})
+	return nil
+}
+
+// loadWordFile 读取敏感词文件，每行一个词，忽略空行和 # 开头的注释
+func loadWordFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取敏感词文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取敏感词文件失败: %w", err)
+	}
+	return words, nil
+}
+
+// ReplaceMessage 返回 replace 时回复的消息
+func (m *Moderator) ReplaceMessage() string {
+	if m == nil {
+		return ""
+	}
+	return m.state.Load().cfg.ReplaceMessage
+}
+
+// Check 审核文本，命中时按该阶段配置的处理方式返回处理后的文本并写入审核记录；
+// 未启用审核时原样通过。答案不支持 block，审核接口要求 block 时按 replace 处理
+func (m *Moderator) Check(ctx context.Context, stage, userID, text string) Result {
+	result := m.check(ctx, stage, userID, text)
+	if result.Action != ActionPass {
+		m.audit(ctx, stage, userID, text, result)
+	}
+	return result
+}
+
+// Passes 判断答案片段是否未命中任何敏感词，不写审核记录；用于流式先行回复的首段，
+// 命中时不先行回复，由完整答案的审核处理
+func (m *Moderator) Passes(ctx context.Context, userID, text string) bool {
+	return m.check(ctx, StageAnswer, userID, text).Action == ActionPass
+}
+
+func (m *Moderator) check(ctx context.Context, stage, userID, text string) Result {
+	result := Result{Action: ActionPass, Text: text}
+	if m == nil {
+		return result
+	}
+	st := m.state.Load()
+	if !st.cfg.Enabled || strings.TrimSpace(text) == "" {
+		return result
+	}
+
+	runes := []rune(text)
+	hits := st.matcher.find(runes)
+	var sources []string
+	if len(hits) > 0 {
+		result.Action = st.stageAction(stage)
+		result.Words = matchedWords(runes, hits)
+		sources = append(sources, "words")
+	}
+
+	if st.cfg.HookURL != "" {
+		hook, err := st.callHook(ctx, stage, userID, text)
+		if err != nil {
+			logging.FromContext(ctx).Warn("调用审核接口失败，只使用敏感词表", "error", err)
+		} else if hook.Action != ActionPass {
+			if actionLevel[hook.Action] > actionLevel[result.Action] {
+				result.Action = hook.Action
+			}
+			hits = append(hits, newMatcher(hook.Words).find(runes)...)
+			result.Words = appendUnique(result.Words, hook.Words...)
+			sources = append(sources, "hook")
+		}
+	}
+	result.Source = strings.Join(sources, "+")
+
+	if stage == StageAnswer && result.Action == ActionBlock {
+		result.Action = ActionReplace
+	}
+	// 审核接口要求屏蔽但没有给出敏感词时，无法定位需要屏蔽的内容，整体替换
+	if result.Action == ActionMask && len(hits) == 0 {
+		result.Action = ActionReplace
+	}
+
+	switch result.Action {
+	case ActionMask:
+		result.Text = mask(runes, hits, []rune(st.cfg.MaskChar)[0])
+	case ActionReplace:
+		result.Text = st.cfg.ReplaceMessage
+	case ActionBlock:
+		result.Text = ""
+	}
+	return result
+}
+
+// stageAction 返回敏感词表命中时该阶段的处理方式
+func (st *moderatorState) stageAction(stage string) string {
+	if stage == StageQuestion {
+		return st.cfg.QuestionAction
+	}
+	return st.cfg.AnswerAction
+}
+
+// hookRequest 是发送给审核接口的请求
+type hookRequest struct {
+	Stage  string `json:"stage"`
+	UserID string `json:"user_id"`
+	Text   string `json:"text"`
+}
+
+// hookResponse 是审核接口的响应，action 为 pass、mask、replace 或 block，mask 时需要在 words 中给出敏感词
+type hookResponse struct {
+	Action string   `json:"action"`
+	Words  []string `json:"words"`
+}
+
+// callHook 调用本地审核接口
+func (st *moderatorState) callHook(ctx context.Context, stage, userID, text string) (*hookResponse, error) {
+	body, _ := json.Marshal(hookRequest{Stage: stage, UserID: userID, Text: text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, st.cfg.HookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("审核接口返回状态码 %d", resp.StatusCode)
+	}
+
+	var result hookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析审核接口响应失败: %w", err)
+	}
+	if result.Action == "" {
+		result.Action = ActionPass
+	}
+	if _, ok := actionLevel[result.Action]; !ok {
+		return nil, fmt.Errorf("审核接口返回未知的 action: %q", result.Action)
+	}
+	return &result, nil
+}
+
+// matchedWords 返回命中的敏感词原文，去除重复
+func matchedWords(runes []rune, hits []match) []string {
+	var words []string
+	for _, hit := range hits {
+		words = appendUnique(words, string(runes[hit.start:hit.end]))
+	}
+	return words
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found && value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// mask 把命中位置的每个字符替换为 maskChar
+func mask(runes []rune, hits []match, maskChar rune) string {
+	masked := append([]rune(nil), runes...)
+	for _, hit := range hits {
+		for i := hit.start; i < hit.end; i++ {
+			masked[i] = maskChar
+		}
+	}
+	return string(masked)
+}
+
+// auditRecord 是审核记录文件中的一行
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	UserID    string    `json:"user_id"`
+	Stage     string    `json:"stage"`
+	Action    string    `json:"action"`
+	Source    string    `json:"source"`
+	Words     []string  `json:"words"`
+	Text      string    `json:"text"`
+}
+
+// audit 记录一次命中：日志中只记录命中的敏感词，审核记录文件中同时保存原文(按日志配置屏蔽个人信息)
+func (m *Moderator) audit(ctx context.Context, stage, userID, text string, result Result) {
+	metrics.ModerationHits.Inc(stage, result.Action)
+	log := logging.FromContext(ctx)
+	log.Warn("内容审核命中", "user_id", userID, "stage", stage, "action", result.Action, "source", result.Source, "words", result.Words)
+
+	path := m.state.Load().cfg.AuditFile
+	if path == "" {
+		return
+	}
+
+	line, _ := json.Marshal(auditRecord{
+		Time:      time.Now(),
+		RequestID: logging.RequestID(ctx),
+		UserID:    userID,
+		Stage:     stage,
+		Action:    result.Action,
+		Source:    result.Source,
+		Words:     result.Words,
+		Text:      logging.MaskPII(text),
+	})
+
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Error("写入审核记录失败", "error", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Error("写入审核记录失败", "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Error("写入审核记录失败", "error", err)
+	}
+}
@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// ErrQueueFull 表示排队的任务数已达上限
+var ErrQueueFull = errors.New("任务队列已满")
+
+// ErrPoolClosed 表示任务池已关闭，不再接受新任务
+var ErrPoolClosed = errors.New("任务池已关闭")
+
+// 默认的工作协程数和队列长度
+const (
+	defaultWorkers   = 4
+	defaultQueueSize = 100
+)
+
+// job 是一个排队中的任务
+type job struct {
+	userID string
+	fn     func() string
+	result chan string
+}
+
+// Pool 是固定数量工作协程加有界队列的任务池，同一用户的任务按提交顺序依次执行
+type Pool struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	jobs      []*job          // 等待执行的任务(先进先出)
+	busyUsers map[string]bool // 正在执行任务的用户
+	running   int             // 正在执行的任务数
+	workers   int
+	queueSize int
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewPool 创建任务池并启动 workers 个工作协程，queueSize 为最多排队的任务数
+func NewPool(workers, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	p := &Pool{
+		busyUsers: make(map[string]bool),
+		workers:   workers,
+		queueSize: queueSize,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Stats 返回排队中和正在执行的任务数
+func (p *Pool) Stats() (queued, running int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.jobs), p.running
+}
+
+// Submit 提交一个任务，返回接收结果的通道和排在它前面的任务数；队列已满时返回 ErrQueueFull
+func (p *Pool) Submit(userID string, fn func() string) (<-chan string, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, 0, ErrPoolClosed
+	}
+	if len(p.jobs) >= p.queueSize {
+		return nil, 0, ErrQueueFull
+	}
+
+	j := &job{userID: userID, fn: fn, result: make(chan string, 1)}
+	ahead := len(p.jobs)
+	if p.running >= p.workers || p.busyUsers[userID] {
+		// 没有可立即执行的工作协程，还需等待正在执行的任务
+		ahead++
+	}
+	p.jobs = append(p.jobs, j)
+	p.cond.Signal()
+
+	return j.result, ahead, nil
+}
+
+// Len 返回正在排队的任务数
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.jobs)
+}
+
+// Running 返回正在执行的任务数
+func (p *Pool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Close 停止接受新任务，等待已提交的任务全部执行完毕
+func (p *Pool) Close() {
+	p.Shutdown(context.Background())
+}
+
+// Shutdown 停止接受新任务并等待已提交的任务执行完毕，ctx 结束时不再等待并返回 ctx 的错误，
+// 未执行完的任务仍会在后台继续
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worker 循环取出可执行的任务并执行
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		j := p.nextJobLocked()
+		for j == nil {
+			if p.closed && len(p.jobs) == 0 {
+				p.mu.Unlock()
+				return
+			}
+			p.cond.Wait()
+			j = p.nextJobLocked()
+		}
+		p.busyUsers[j.userID] = true
+		p.running++
+		p.mu.Unlock()
+
+		j.result <- runJob(j.fn)
+
+		p.mu.Lock()
+		delete(p.busyUsers, j.userID)
+		p.running--
+		// 该用户的下一个任务可能在等待
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// nextJobLocked 取出队列中第一个所属用户当前没有任务在执行的任务
+func (p *Pool) nextJobLocked() *job {
+	for i, j := range p.jobs {
+		if p.busyUsers[j.userID] {
+			continue
+		}
+		p.jobs = append(p.jobs[:i], p.jobs[i+1:]...)
+		return j
+	}
+	return nil
+}
+
+// runJob 执行任务，任务panic时返回错误提示
+func runJob(fn func() string) (result string) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("执行任务时发生错误", "panic", r)
+			result = "抱歉，处理您的问题时发生了错误，请稍后再试。"
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,92 @@
+package ragflow
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// 回答模式
+const (
+	AnswerModeChat         = "chat"          // 使用RAGFlow聊天助手回答
+	AnswerModeRetrieveLLM  = "retrieve+llm"  // 检索知识库后由模型根据检索内容回答
+	AnswerModeRetrieveOnly = "retrieve-only" // 只返回检索到的知识片段摘录
+)
+
+// 组装提示词和摘录时使用的知识片段数量与长度限制
+const (
+	promptChunkCount   = 5
+	promptChunkRunes   = 800
+	excerptChunkCount  = 3
+	excerptChunkRunes  = 200
+	noChunksFoundReply = "抱歉，知识库中未找到相关内容。"
+)
+
+// NormalizeAnswerMode 返回有效的回答模式，未配置或无法识别时使用 chat
+func NormalizeAnswerMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case AnswerModeRetrieveLLM:
+		return AnswerModeRetrieveLLM
+	case AnswerModeRetrieveOnly:
+		return AnswerModeRetrieveOnly
+	case "", AnswerModeChat:
+		return AnswerModeChat
+	default:
+		slog.Warn("未知的回答模式，使用 chat 模式", "answer_mode", mode)
+		return AnswerModeChat
+	}
+}
+
+// BuildPrompt 根据检索到的知识片段组装交给模型的提示词
+func BuildPrompt(question string, chunks []Chunk) string {
+	var b strings.Builder
+	b.WriteString("请根据以下知识库内容回答用户的问题。如果知识库内容与问题无关，请直接说明无法从知识库中找到答案，不要编造。\n\n知识库内容:\n")
+
+	for i, chunk := range chunks {
+		if i >= promptChunkCount {
+			break
+		}
+		b.WriteString(fmt.Sprintf("[%d]", i+1))
+		if chunk.DocumentName != "" {
+			b.WriteString(" 来源: " + chunk.DocumentName)
+		}
+		b.WriteString("\n")
+		b.WriteString(truncateRunes(strings.TrimSpace(chunk.Content), promptChunkRunes))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("用户问题: ")
+	b.WriteString(question)
+	return b.String()
+}
+
+// FormatExcerpt 把检索到的前几个知识片段整理成摘录形式的答案
+func FormatExcerpt(chunks []Chunk) string {
+	if len(chunks) == 0 {
+		return noChunksFoundReply
+	}
+
+	var b strings.Builder
+	b.WriteString("以下是知识库中与您的问题最相关的内容：")
+
+	for i, chunk := range chunks {
+		if i >= excerptChunkCount {
+			break
+		}
+		b.WriteString(fmt.Sprintf("\n\n%d. %s", i+1, truncateRunes(strings.TrimSpace(chunk.Content), excerptChunkRunes)))
+		if chunk.DocumentName != "" {
+			b.WriteString(fmt.Sprintf("\n(来源: %s)", chunk.DocumentName))
+		}
+	}
+
+	return b.String()
+}
+
+// truncateRunes 按字符数截断文本，超出时追加省略号
+func truncateRunes(text string, maxRunes int) string {
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	return string([]rune(text)[:maxRunes]) + "……"
+}
@@ -0,0 +1,204 @@
+package ragflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReferenceChunk 是答案引用的一个知识片段
+type ReferenceChunk struct {
+	ID           string  `json:"id"`
+	Content      string  `json:"content"`
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	Similarity   float64 `json:"similarity"`
+}
+
+// DocAgg 是按文档汇总的引用信息
+type DocAgg struct {
+	DocName string `json:"doc_name"`
+	DocID   string `json:"doc_id"`
+	Count   int    `json:"count"`
+}
+
+// Reference 是RAGFlow答案中的引用块，答案中的 ##n$$ 标记对应 Chunks[n]
+type Reference struct {
+	Total   int              `json:"total"`
+	Chunks  []ReferenceChunk `json:"chunks"`
+	DocAggs []DocAgg         `json:"doc_aggs"`
+}
+
+// 答案中的引用标记，新版为 ##n$$，旧版为 [ID:n]
+var citationPattern = regexp.MustCompile(`##(\d+)\$\$|\[ID:(\d+)\]`)
+
+// 引用片段在 /sources 中展示的最大字符数
+const sourceSnippetRunes = 80
+
+// parseReference 解析响应中的引用块，引用为空时返回 nil
+func parseReference(raw json.RawMessage) *Reference {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var ref Reference
+	if err := json.Unmarshal(raw, &ref); err != nil {
+		return nil
+	}
+	if len(ref.Chunks) == 0 && len(ref.DocAggs) == 0 {
+		return nil
+	}
+	return &ref
+}
+
+// extractReference 从对话接口的JSON响应中提取引用块
+func extractReference(result map[string]interface{}) *Reference {
+	data, ok := result["data"].(map[string]interface{})
+	if !ok || data["reference"] == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(data["reference"])
+	if err != nil {
+		return nil
+	}
+	return parseReference(raw)
+}
+
+// ReferenceFromChunks 根据检索结果构造引用块，用于检索模式下的参考资料
+func ReferenceFromChunks(chunks []Chunk) *Reference {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	ref := &Reference{Total: len(chunks)}
+	for _, chunk := range chunks {
+		ref.Chunks = append(ref.Chunks, ReferenceChunk{
+			Content:      chunk.Content,
+			DocumentName: chunk.DocumentName,
+			Similarity:   chunk.Similarity,
+		})
+	}
+	return ref
+}
+
+// citationIndex 返回引用标记对应的片段序号
+func citationIndex(marker string) int {
+	groups := citationPattern.FindStringSubmatch(marker)
+	num := groups[1]
+	if num == "" {
+		num = groups[2]
+	}
+	idx, _ := strconv.Atoi(num)
+	return idx
+}
+
+// CitedChunks 按在答案中首次出现的顺序返回被引用的片段序号，第i个元素对应脚注[i+1]
+func CitedChunks(answer string) []int {
+	var cited []int
+	seen := make(map[int]bool)
+	for _, marker := range citationPattern.FindAllString(answer, -1) {
+		idx := citationIndex(marker)
+		if !seen[idx] {
+			seen[idx] = true
+			cited = append(cited, idx)
+		}
+	}
+	return cited
+}
+
+// ReplaceCitations 把答案中的引用标记替换为 [1]、[2] 形式的脚注，编号按首次出现的顺序分配
+func ReplaceCitations(answer string) string {
+	footnotes := make(map[int]int)
+	return citationPattern.ReplaceAllStringFunc(answer, func(marker string) string {
+		idx := citationIndex(marker)
+		num, ok := footnotes[idx]
+		if !ok {
+			num = len(footnotes) + 1
+			footnotes[idx] = num
+		}
+		return fmt.Sprintf("[%d]", num)
+	})
+}
+
+// FormatSources 生成答案末尾的参考资料，cited 为 CitedChunks 的结果；答案未引用具体片段时列出全部引用文档
+func FormatSources(cited []int, ref *Reference) string {
+	if ref == nil {
+		return ""
+	}
+
+	var lines []string
+	if len(cited) > 0 {
+		// 同一文档的多个脚注合并为一行
+		var names []string
+		footnotes := make(map[string]string)
+		for i, idx := range cited {
+			if idx < 0 || idx >= len(ref.Chunks) {
+				continue
+			}
+			name := ref.Chunks[idx].DocumentName
+			if name == "" {
+				continue
+			}
+			if _, ok := footnotes[name]; !ok {
+				names = append(names, name)
+			}
+			footnotes[name] += fmt.Sprintf("[%d]", i+1)
+		}
+		for _, name := range names {
+			lines = append(lines, footnotes[name]+" "+name)
+		}
+	} else {
+		for i, name := range ref.documentNames() {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, name))
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return "参考资料：\n" + strings.Join(lines, "\n")
+}
+
+// FormatReferenceList 生成完整的引用列表，包括每个文档被引用的片段摘要
+func FormatReferenceList(ref *Reference) string {
+	if ref == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, name := range ref.documentNames() {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(fmt.Sprintf("%d. %s", i+1, name))
+		for j, chunk := range ref.Chunks {
+			if chunk.DocumentName == name {
+				b.WriteString(fmt.Sprintf("\n  [片段%d] %s", j+1, truncateRunes(strings.TrimSpace(chunk.Content), sourceSnippetRunes)))
+			}
+		}
+	}
+	return b.String()
+}
+
+// documentNames 返回引用的文档名称列表(去重，保持顺序)
+func (r *Reference) documentNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, agg := range r.DocAggs {
+		add(agg.DocName)
+	}
+	for _, chunk := range r.Chunks {
+		add(chunk.DocumentName)
+	}
+	return names
+}
@@ -0,0 +1,124 @@
+package ragflow
+
+import (
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+)
+
+// RetrievalOptions 是检索接口的参数，数值为0、为空或为nil时使用RAGFlow的默认值
+type RetrievalOptions struct {
+	DatasetIDs             []string
+	TopK                   int
+	SimilarityThreshold    *float64 // 可以为0，表示不按相似度过滤
+	VectorSimilarityWeight *float64 // 可以为0，表示只使用关键词相似度
+	Keyword                bool
+	RerankID               string
+	Highlight              bool
+	PageSize               int
+}
+
+// RetrievalOverride 是单个请求对检索参数的覆盖，DatasetIDs 非空或其余字段非nil时生效
+type RetrievalOverride struct {
+	DatasetIDs []string
+	config.RetrievalOverrideConfig
+}
+
+// RetrievalOptionsFromConfig 根据配置生成默认的检索参数
+func RetrievalOptionsFromConfig(cfg config.RagFlowConfig) RetrievalOptions {
+	r := cfg.Retrieval
+	datasetIDs := r.DatasetIDs
+	if len(datasetIDs) == 0 && cfg.DatasetID != "" {
+		datasetIDs = []string{cfg.DatasetID}
+	}
+
+	opts := RetrievalOptions{
+		DatasetIDs: datasetIDs,
+		TopK:       r.TopK,
+		Keyword:    r.Keyword,
+		RerankID:   r.RerankID,
+		Highlight:  r.Highlight,
+		PageSize:   r.PageSize,
+	}
+	// 全局配置中的0表示使用RAGFlow的默认值
+	if r.SimilarityThreshold > 0 {
+		opts.SimilarityThreshold = &r.SimilarityThreshold
+	}
+	if r.VectorSimilarityWeight > 0 {
+		opts.VectorSimilarityWeight = &r.VectorSimilarityWeight
+	}
+	return opts
+}
+
+// merge 用 override 中填写的项覆盖当前参数，0和false同样生效
+func (o RetrievalOptions) merge(override RetrievalOverride) RetrievalOptions {
+	if len(override.DatasetIDs) > 0 {
+		o.DatasetIDs = override.DatasetIDs
+	}
+	if override.TopK != nil {
+		o.TopK = *override.TopK
+	}
+	if override.SimilarityThreshold != nil {
+		o.SimilarityThreshold = override.SimilarityThreshold
+	}
+	if override.VectorSimilarityWeight != nil {
+		o.VectorSimilarityWeight = override.VectorSimilarityWeight
+	}
+	if override.Keyword != nil {
+		o.Keyword = *override.Keyword
+	}
+	if override.RerankID != nil {
+		o.RerankID = *override.RerankID
+	}
+	if override.Highlight != nil {
+		o.Highlight = *override.Highlight
+	}
+	if override.PageSize != nil {
+		o.PageSize = *override.PageSize
+	}
+	return o
+}
+
+// requestBody 生成检索接口的请求体，未设置的参数不发送
+func (o RetrievalOptions) requestBody(question string) map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"question":    question,
+		"dataset_ids": o.DatasetIDs,
+	}
+
+	if o.TopK > 0 {
+		reqBody["top_k"] = o.TopK
+	}
+	if o.SimilarityThreshold != nil {
+		reqBody["similarity_threshold"] = *o.SimilarityThreshold
+	}
+	if o.VectorSimilarityWeight != nil {
+		reqBody["vector_similarity_weight"] = *o.VectorSimilarityWeight
+	}
+	if o.Keyword {
+		reqBody["keyword"] = true
+	}
+	if o.RerankID != "" {
+		reqBody["rerank_id"] = o.RerankID
+	}
+	if o.Highlight {
+		reqBody["highlight"] = true
+	}
+	if o.PageSize > 0 {
+		reqBody["page_size"] = o.PageSize
+	}
+	return reqBody
+}
+
+// filter 丢弃相似度低于阈值的片段
+func (o RetrievalOptions) filter(chunks []Chunk) []Chunk {
+	if o.SimilarityThreshold == nil || *o.SimilarityThreshold <= 0 {
+		return chunks
+	}
+
+	var passed []Chunk
+	for _, chunk := range chunks {
+		if chunk.Similarity >= *o.SimilarityThreshold {
+			passed = append(passed, chunk)
+		}
+	}
+	return passed
+}
@@ -6,62 +6,167 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
 )
 
-// 全局配置变量
-var (
-	cfg            = config.GetConfig()
-	RagFlowBaseURL = cfg.RagFlow.BaseURL
-	ApiKey         = cfg.RagFlow.ApiKey
-	ChatID         = cfg.RagFlow.ChatID
-	DatasetID      = cfg.RagFlow.DatasetID
-	MaxRetries     = cfg.RagFlow.MaxRetries
-	RetryInterval  = time.Duration(cfg.RagFlow.RetryInterval) * time.Second
-	RequestTimeout = time.Duration(cfg.RagFlow.RequestTimeout) * time.Second
-)
+// Service 是RAGFlow服务的接口，便于替换实现或在测试中使用模拟服务
+// ctx 携带消息的关联ID，请求日志都会带上该ID
+type Service interface {
+	// Retrieve 检索知识库中与问题相关的 chunk，override 中的非零值覆盖配置中的检索参数
+	Retrieve(ctx context.Context, question string, override *RetrievalOverride) ([]Chunk, error)
+	// Complete 在用户的会话中向聊天助手提问，返回完整答案和引用块(没有引用时为nil)
+	Complete(ctx context.Context, question, userID string) (string, *Reference, error)
+	// CompleteStream 使用流式接口提问，每收到新内容时以当前完整答案调用 onPartial
+	CompleteStream(ctx context.Context, question, userID string, onPartial func(answer string)) (string, *Reference, error)
+	// QueryLLMFreeAnswer 不经过知识库，直接把提示词交给聊天助手的模型作答
+	QueryLLMFreeAnswer(ctx context.Context, prompt string) (string, error)
+	// CreateSession 创建新的会话，返回会话ID
+	CreateSession(ctx context.Context, sessionName string) (string, error)
+	// DeleteSession 删除指定的会话
+	DeleteSession(ctx context.Context, sessionID string) error
+	// SessionID 查询用户在当前聊天助手下的会话ID
+	SessionID(userID string) (string, bool)
+	// ClearSession 清除用户的会话，下次提问时会创建新会话
+	ClearSession(ctx context.Context, userID string)
+	// ForChat 返回使用指定聊天助手的服务，chatID 为空或与当前相同时返回自身
+	ForChat(chatID string) Service
+}
+
+// SessionStore 保存用户与RAGFlow会话的映射
+type SessionStore interface {
+	GetUserSession(userID string) (string, bool, error)
+	SaveUserSession(userID, sessionID string) error
+	DeleteUserSession(userID string) error
+}
+
+// Client 是基于HTTP的RAGFlow客户端
+type Client struct {
+	chatID string                        // ForChat 指定的聊天助手ID，为空时使用配置中的默认聊天助手
+	conf   *atomic.Pointer[clientConfig] // 与 ForChat 返回的客户端共享，UpdateConfig 时整体替换
+
+	streamClient *http.Client // 流式响应持续时间较长，由context控制整体超时
+	sessions     SessionStore // 用户ID -> 会话ID
+}
+
+// clientConfig 是客户端当前使用的配置
+type clientConfig struct {
+	baseURL       string
+	apiKey        string
+	defaultChat   string // 配置中的默认聊天助手ID，其他助手的会话单独保存
+	retrieval     RetrievalOptions
+	maxRetries    int
+	retryInterval time.Duration
+	httpClient    *http.Client // 超时为配置的 request_timeout
+}
+
+// NewClient 根据RAGFlow配置创建客户端，sessions 用于保存用户的会话映射
+func NewClient(cfg config.RagFlowConfig, sessions SessionStore) *Client {
+	c := &Client{
+		conf:         &atomic.Pointer[clientConfig]{},
+		streamClient: &http.Client{},
+		sessions:     sessions,
+	}
+	c.UpdateConfig(cfg)
+	return c
+}
+
+// UpdateConfig 替换客户端的地址、API Key、重试和超时配置，已缓存的会话映射保留
+func (c *Client) UpdateConfig(cfg config.RagFlowConfig) {
+	requestTimeout := time.Duration(cfg.RequestTimeout) * time.Second
+	c.conf.Store(&clientConfig{
+		baseURL:       cfg.BaseURL,
+		apiKey:        cfg.ApiKey,
+		defaultChat:   cfg.ChatID,
+		retrieval:     RetrievalOptionsFromConfig(cfg),
+		maxRetries:    cfg.MaxRetries,
+		retryInterval: time.Duration(cfg.RetryInterval) * time.Second,
+		httpClient:    &http.Client{Timeout: requestTimeout},
+	})
+}
+
+// config 返回客户端当前的配置
+func (c *Client) config() *clientConfig {
+	return c.conf.Load()
+}
+
+// chat 返回当前使用的聊天助手ID
+func (c *Client) chat(conf *clientConfig) string {
+	if c.chatID != "" {
+		return c.chatID
+	}
+	return conf.defaultChat
+}
+
+// ForChat 返回使用指定聊天助手的客户端，与原客户端共享配置、HTTP连接和会话存储
+func (c *Client) ForChat(chatID string) Service {
+	if chatID == "" || chatID == c.chat(c.config()) {
+		return c
+	}
+
+	clone := *c
+	clone.chatID = chatID
+	return &clone
+}
+
+// sessionKey 返回用户在当前聊天助手下的会话映射key，会话只在所属的聊天助手中有效
+func (c *Client) sessionKey(userID string) string {
+	if c.chatID == "" || c.chatID == c.config().defaultChat {
+		return userID
+	}
+	return userID + "@" + c.chatID
+}
 
 type Chunk struct {
-	Content      string `json:"content"`
-	DocumentName string `json:"document_name"`
+	Content      string  `json:"content"`
+	DocumentName string  `json:"document_keyword"`
+	Similarity   float64 `json:"similarity"`
 }
 
 type RetrievalResponse struct {
-	Code int     `json:"code"`
-	Data []Chunk `json:"data"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Chunks []Chunk `json:"chunks"`
+		Total  int     `json:"total"`
+	} `json:"data"`
 }
 
-// RetrieveChunks 检索知识库中的 chunk
-func RetrieveChunks(question string) ([]Chunk, error) {
+// Retrieve 检索知识库中的 chunk，相似度低于阈值的片段会被丢弃
+func (c *Client) Retrieve(ctx context.Context, question string, override *RetrievalOverride) ([]Chunk, error) {
+	conf := c.config()
 	// 使用v1版本的检索API
-	url := fmt.Sprintf("%s/api/v1/retrieval", RagFlowBaseURL)
+	url := fmt.Sprintf("%s/api/v1/retrieval", conf.baseURL)
 
-	// 使用更通用的请求结构
-	reqBody := map[string]interface{}{
-		"question":    question,
-		"dataset_ids": []string{DatasetID},
-		"top_k":       5,
+	opts := conf.retrieval
+	if override != nil {
+		opts = opts.merge(*override)
 	}
+	reqBody := opts.requestBody(question)
 
 	body, _ := json.Marshal(reqBody)
 
 	// 发送POST请求
-	respBody, err := makeHTTPRequestWithRetry("POST", url, body)
+	respBody, err := c.makeHTTPRequestWithRetry(ctx, endpointRetrieval, "POST", url, body)
 	if err != nil {
-		fmt.Println("检索知识块失败:", err)
-		return []Chunk{}, nil // 失败时返回空结果而不报错，以便继续后续处理
+		return nil, err
 	}
 
 	var result RetrievalResponse
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return []Chunk{}, nil
+		return nil, fmt.Errorf("解析检索结果失败: %w", err)
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("API错误: %s (代码: %d)", result.Message, result.Code)
 	}
 
-	return result.Data, nil
+	return opts.filter(result.Data.Chunks), nil
 }
 
 type CompletionRequest struct {
@@ -78,91 +183,116 @@ type CompletionResponse struct {
 	} `json:"data"`
 }
 
-// 会话缓存
-var (
-	sessionCache     = make(map[string]string) // 用户ID -> 会话ID的映射
-	sessionCacheLock sync.RWMutex
-)
+// ensureSession 确保用户的会话存在
+func (c *Client) ensureSession(ctx context.Context, userID string) (string, error) {
+	conf := c.config()
+	log := logging.FromContext(ctx)
 
-// 确保会话存在
-func EnsureSession(userID string) (string, error) {
-	// 检查缓存中是否已有该用户的会话ID
-	sessionCacheLock.RLock()
-	sessionID, exists := sessionCache[userID]
-	sessionCacheLock.RUnlock()
+	// 检查存储中是否已有该用户的会话ID
+	sessionID, exists, err := c.sessions.GetUserSession(c.sessionKey(userID))
+	if err != nil {
+		log.Error("读取会话映射失败", "error", err)
+	}
 
 	// 如果已存在有效的会话ID，直接返回
 	if exists && sessionID != "" {
 		// 检查会话是否仍然有效（可以添加会话有效性检查）
-		fmt.Println("使用缓存的会话ID:", sessionID, "用户ID:", userID)
+		log.Debug("使用缓存的会话", "session_id", sessionID, "user_id", userID)
 		return sessionID, nil
 	}
 
 	// 如果不存在，创建新会话
 	sessionName := "wechat_" + userID
-	fmt.Println("创建新会话，名称:", sessionName)
+	log.Info("创建新会话", "session_name", sessionName)
 
 	// 添加重试逻辑
 	var newSessionID string
 	var createErr error
 
-	for retries := 0; retries <= MaxRetries; retries++ {
+	for retries := 0; retries <= conf.maxRetries; retries++ {
 		if retries > 0 {
-			time.Sleep(RetryInterval * time.Duration(retries))
-			fmt.Printf("第%d次重试创建会话\n", retries)
+			time.Sleep(conf.retryInterval * time.Duration(retries))
+			log.Info("重试创建会话", "retry", retries)
 		}
 
 		// 创建新会话
-		newSessionID, createErr = CreateSession(sessionName)
+		newSessionID, createErr = c.CreateSession(ctx, sessionName)
 		if createErr == nil {
 			break
 		}
 
-		fmt.Printf("创建会话失败(尝试%d/%d): %v\n", retries+1, MaxRetries+1, createErr)
-		if retries == MaxRetries {
+		log.Warn("创建会话失败", "attempt", retries+1, "max_attempts", conf.maxRetries+1, "error", createErr)
+		if retries == conf.maxRetries {
 			return "", createErr
 		}
 	}
 
-	// 保存到缓存
-	sessionCacheLock.Lock()
-	sessionCache[userID] = newSessionID
-	sessionCacheLock.Unlock()
+	// 保存到存储
+	if err := c.sessions.SaveUserSession(c.sessionKey(userID), newSessionID); err != nil {
+		log.Error("保存会话映射失败", "error", err)
+	}
 
-	fmt.Println("已缓存会话ID:", newSessionID, "用户ID:", userID)
+	log.Info("已缓存会话", "session_id", newSessionID, "user_id", userID)
 	return newSessionID, nil
 }
 
-// 清理指定用户的会话缓存
-func ClearSessionCache(userID string) {
-	sessionCacheLock.Lock()
-	defer sessionCacheLock.Unlock()
+// SessionID 查询用户在当前聊天助手下缓存的会话ID
+func (c *Client) SessionID(userID string) (string, bool) {
+	sessionID, exists, err := c.sessions.GetUserSession(c.sessionKey(userID))
+	if err != nil {
+		slog.Error("读取会话映射失败", "error", err)
+	}
+	return sessionID, exists && sessionID != ""
+}
+
+// ClearSession 清理指定用户的会话缓存，并在后台删除RAGFlow中的会话
+func (c *Client) ClearSession(ctx context.Context, userID string) {
+	log := logging.FromContext(ctx)
 
-	delete(sessionCache, userID)
-	fmt.Println("已清理用户会话缓存:", userID)
+	sessionID, exists, _ := c.sessions.GetUserSession(c.sessionKey(userID))
+	if err := c.sessions.DeleteUserSession(c.sessionKey(userID)); err != nil {
+		log.Error("删除会话映射失败", "error", err)
+	}
+
+	log.Info("已清理用户会话缓存", "user_id", userID)
+
+	if exists && sessionID != "" {
+		go func() {
+			if err := c.DeleteSession(ctx, sessionID); err != nil {
+				log.Warn("删除RAGFlow会话失败", "session_id", sessionID, "error", err)
+			}
+		}()
+	}
 }
 
-// QueryRagFlow 调用 RAGFlow 获取答案（基于知识库）
-func QueryRagFlow(question, userID string) (string, error) {
+// Complete 调用 RAGFlow 获取答案（基于知识库）
+func (c *Client) Complete(ctx context.Context, question, userID string) (string, *Reference, error) {
+	conf := c.config()
+	log := logging.FromContext(ctx)
+
 	// 设置超时context
-	ctx, cancel := context.WithTimeout(context.Background(), 140*time.Second) // 从35秒增加到140秒
+	ctx, cancel := context.WithTimeout(ctx, 140*time.Second) // 从35秒增加到140秒
 	defer cancel()
 
 	// 创建结果通道
-	resultChan := make(chan string, 1)
+	type completion struct {
+		answer    string
+		reference *Reference
+	}
+	resultChan := make(chan completion, 1)
 	errChan := make(chan error, 1)
 
 	go func() {
 		// 确保会话存在
-		sessionID, err := EnsureSession(userID)
+		sessionID, err := c.ensureSession(ctx, userID)
 		if err != nil {
-			fmt.Println("创建会话失败:", err)
+			log.Error("创建会话失败", "error", err)
 			errChan <- err
 			return
 		}
 
 		// 使用正确的API路径
-		url := fmt.Sprintf("%s/api/v1/chats/%s/completions", RagFlowBaseURL, ChatID)
+		url := fmt.Sprintf("%s/api/v1/chats/%s/completions", conf.baseURL, c.chat(conf))
 
 		// 构造请求
 		reqBody := map[string]interface{}{
@@ -174,25 +304,25 @@ func QueryRagFlow(question, userID string) (string, error) {
 		body, _ := json.Marshal(reqBody)
 
 		// 发送请求并处理响应
-		fmt.Println("发送RAGFlow查询，会话ID:", sessionID)
+		log.Info("发送RAGFlow查询", "session_id", sessionID)
 
 		// 添加重试逻辑
 		var respBody []byte
 		var reqErr error
-		for retries := 0; retries <= MaxRetries; retries++ {
+		for retries := 0; retries <= conf.maxRetries; retries++ {
 			if retries > 0 {
 				// 如果是重试，等待一段时间
-				time.Sleep(RetryInterval * time.Duration(retries))
-				fmt.Printf("第%d次重试RAGFlow查询\n", retries)
+				time.Sleep(conf.retryInterval * time.Duration(retries))
+				log.Info("重试RAGFlow查询", "retry", retries)
 			}
 
-			respBody, reqErr = makeHTTPRequestWithRetry("POST", url, body)
+			respBody, reqErr = c.makeHTTPRequestWithRetry(ctx, endpointCompletions, "POST", url, body)
 			if reqErr == nil {
 				break
 			}
 
-			fmt.Printf("RAGFlow查询失败(尝试%d/%d): %v\n", retries+1, MaxRetries+1, reqErr)
-			if retries == MaxRetries {
+			log.Warn("RAGFlow查询失败", "attempt", retries+1, "max_attempts", conf.maxRetries+1, "error", reqErr)
+			if retries == conf.maxRetries {
 				errChan <- reqErr
 				return
 			}
@@ -201,7 +331,7 @@ func QueryRagFlow(question, userID string) (string, error) {
 		// 使用通用JSON解析以适应可能的不同响应结构
 		var result map[string]interface{}
 		if err := json.Unmarshal(respBody, &result); err != nil {
-			fmt.Println("解析响应失败:", err)
+			log.Error("解析响应失败", "error", err)
 			errChan <- err
 			return
 		}
@@ -209,25 +339,25 @@ func QueryRagFlow(question, userID string) (string, error) {
 		// 提取回答，处理不同可能的响应结构
 		answer := extractAnswer(result)
 		if answer == "" {
-			fmt.Println("无法从响应中提取有效答案")
+			log.Error("无法从响应中提取有效答案")
 			errChan <- fmt.Errorf("无法提取答案")
 			return
 		}
 
-		resultChan <- answer
+		resultChan <- completion{answer: answer, reference: extractReference(result)}
 	}()
 
 	// 等待结果或超时
 	select {
-	case answer := <-resultChan:
-		return answer, nil
+	case result := <-resultChan:
+		return result.answer, result.reference, nil
 	case err := <-errChan:
 		// 返回错误信息
-		return fmt.Sprintf("抱歉，我无法回答这个问题。系统错误: %v", err), err
+		return fmt.Sprintf("抱歉，我无法回答这个问题。系统错误: %v", err), nil, err
 	case <-ctx.Done():
-		fmt.Println("RAGFlow查询超时")
+		log.Warn("RAGFlow查询超时")
 		// 返回超时错误
-		return "抱歉，响应超时。请稍后再试。", ctx.Err()
+		return "抱歉，响应超时。请稍后再试。", nil, ctx.Err()
 	}
 }
 
@@ -277,10 +407,11 @@ func extractAnswer(result map[string]interface{}) string {
 	return ""
 }
 
-// QueryLLMFreeAnswer 使用模型直接回答问题
-func QueryLLMFreeAnswer(question string) (string, error) {
+// QueryLLMFreeAnswer 使用模型直接回答问题，失败时返回默认答案和错误
+func (c *Client) QueryLLMFreeAnswer(ctx context.Context, question string) (string, error) {
+	conf := c.config()
 	// 使用OpenAI兼容API
-	url := fmt.Sprintf("%s/api/v1/chats_openai/%s/chat/completions", RagFlowBaseURL, ChatID)
+	url := fmt.Sprintf("%s/api/v1/chats_openai/%s/chat/completions", conf.baseURL, c.chat(conf))
 
 	// 按OpenAI格式构造请求
 	reqBody := map[string]interface{}{
@@ -297,22 +428,25 @@ func QueryLLMFreeAnswer(question string) (string, error) {
 	body, _ := json.Marshal(reqBody)
 
 	// 发送请求并处理响应
-	respBody, err := makeHTTPRequestWithRetry("POST", url, body)
+	respBody, err := c.makeHTTPRequestWithRetry(ctx, endpointOpenAI, "POST", url, body)
 	if err != nil {
-		fmt.Println("LLM查询失败:", err)
-		return getDefaultAnswer(question), nil
+		logging.FromContext(ctx).Error("LLM查询失败", "error", err)
+		return getDefaultAnswer(question), err
 	}
 
 	// 使用通用JSON解析
 	var result map[string]interface{}
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return getDefaultAnswer(question), nil
+		return getDefaultAnswer(question), err
 	}
 
 	// 提取回答
 	answer := extractAnswer(result)
 	if answer == "" {
-		return getDefaultAnswer(question), nil
+		if err := checkAPIError(result); err != nil {
+			return getDefaultAnswer(question), err
+		}
+		return getDefaultAnswer(question), fmt.Errorf("无法提取答案")
 	}
 
 	return answer, nil
@@ -324,9 +458,10 @@ func getDefaultAnswer(question string) string {
 }
 
 // CreateSession 创建新的会话，如果会话已存在则返回现有会话ID
-func CreateSession(sessionName string) (string, error) {
+func (c *Client) CreateSession(ctx context.Context, sessionName string) (string, error) {
+	conf := c.config()
 	// 使用正确的会话创建API路径
-	url := fmt.Sprintf("%s/api/v1/chats/%s/sessions", RagFlowBaseURL, ChatID)
+	url := fmt.Sprintf("%s/api/v1/chats/%s/sessions", conf.baseURL, c.chat(conf))
 
 	reqBody := map[string]interface{}{
 		"name": sessionName,
@@ -335,9 +470,8 @@ func CreateSession(sessionName string) (string, error) {
 	body, _ := json.Marshal(reqBody)
 
 	// 发送请求并处理响应
-	respBody, err := makeHTTPRequestWithRetry("POST", url, body)
+	respBody, err := c.makeHTTPRequestWithRetry(ctx, endpointCreateSession, "POST", url, body)
 	if err != nil {
-		fmt.Println("创建会话请求失败:", err)
 		return "", err
 	}
 
@@ -347,12 +481,8 @@ func CreateSession(sessionName string) (string, error) {
 	}
 
 	// 检查API响应
-	if code, ok := result["code"].(float64); ok && code != 0 {
-		message := "未知错误"
-		if msg, ok := result["message"].(string); ok {
-			message = msg
-		}
-		return "", fmt.Errorf("API错误: %s (代码: %.0f)", message, code)
+	if err := checkAPIError(result); err != nil {
+		return "", err
 	}
 
 	// 获取会话ID
@@ -360,66 +490,119 @@ func CreateSession(sessionName string) (string, error) {
 	if data, ok := result["data"].(map[string]interface{}); ok {
 		if id, ok := data["id"].(string); ok {
 			sessionID = id
-			fmt.Println("成功创建/获取会话ID:", sessionID)
+			logging.FromContext(ctx).Info("成功创建会话", "session_id", sessionID)
 		}
 	}
 
 	return sessionID, nil
 }
 
-// makeHTTPRequestWithRetry 带重试机制的HTTP请求
-func makeHTTPRequestWithRetry(method, url string, body []byte) ([]byte, error) {
+// DeleteSession 删除RAGFlow中的会话
+func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
+	conf := c.config()
+	url := fmt.Sprintf("%s/api/v1/chats/%s/sessions", conf.baseURL, c.chat(conf))
+
+	reqBody := map[string]interface{}{
+		"ids": []string{sessionID},
+	}
+
+	body, _ := json.Marshal(reqBody)
+
+	respBody, err := c.makeHTTPRequestWithRetry(ctx, endpointDeleteSession, "DELETE", url, body)
+	if err != nil {
+		return err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+
+	if err := checkAPIError(result); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("已删除RAGFlow会话", "session_id", sessionID)
+	return nil
+}
+
+// checkAPIError 检查RAGFlow响应中的错误码
+func checkAPIError(result map[string]interface{}) error {
+	if code, ok := result["code"].(float64); ok && code != 0 {
+		message := "未知错误"
+		if msg, ok := result["message"].(string); ok {
+			message = msg
+		}
+		return fmt.Errorf("API错误: %s (代码: %.0f)", message, code)
+	}
+	return nil
+}
+
+// 监控指标中的RAGFlow接口名称
+const (
+	endpointRetrieval        = "retrieval"
+	endpointCompletions      = "completions"
+	endpointCompletionStream = "completions_stream"
+	endpointOpenAI           = "chats_openai"
+	endpointCreateSession    = "create_session"
+	endpointDeleteSession    = "delete_session"
+)
+
+// makeHTTPRequestWithRetry 带重试机制的HTTP请求，endpoint 为监控指标和日志中的接口名称
+// 请求头中的API密钥不会写入日志，请求和响应体只在开启 log_bodies 时记录
+func (c *Client) makeHTTPRequestWithRetry(ctx context.Context, endpoint, method, url string, body []byte) ([]byte, error) {
+	conf := c.config()
 	var lastErr error
+	log := logging.FromContext(ctx).With("endpoint", endpoint)
 
-	for i := 0; i <= MaxRetries; i++ {
+	start := time.Now()
+	defer func() {
+		metrics.RagflowRequestDuration.Observe(time.Since(start).Seconds(), endpoint)
+	}()
+
+	for i := 0; i <= conf.maxRetries; i++ {
 		// 如果不是第一次请求，等待一段时间后重试
 		if i > 0 {
-			time.Sleep(RetryInterval)
-			fmt.Printf("重试第%d次请求: %s\n", i, url)
+			time.Sleep(conf.retryInterval)
+			metrics.RagflowRetries.Inc(endpoint)
+			log.Info("重试RAGFlow请求", "retry", i)
 		}
 
 		// 创建HTTP请求
-		req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 		if err != nil {
 			lastErr = err
 			continue
 		}
 
-		req.Header.Set("Authorization", "Bearer "+ApiKey)
+		req.Header.Set("Authorization", "Bearer "+conf.apiKey)
 		req.Header.Set("Content-Type", "application/json")
 
-		// 创建带超时的HTTP客户端
-		client := &http.Client{
-			Timeout: RequestTimeout,
-		}
-
 		// 记录请求详情
-		fmt.Printf("发送%s请求到: %s\n请求体: %s\n", method, url, string(body))
+		log.Debug("发送RAGFlow请求", "method", method, "url", url, logging.Body("request_body", string(body)))
 
 		// 发送请求
-		resp, err := client.Do(req)
+		resp, err := conf.httpClient.Do(req)
 		if err != nil {
 			lastErr = err
-			fmt.Printf("HTTP请求失败: %v\n", err)
+			log.Warn("RAGFlow请求失败", "error", err)
 			continue
 		}
 
-		// 确保响应体被关闭
-		defer resp.Body.Close()
-
 		// 读取响应体
 		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			lastErr = err
 			continue
 		}
 
 		// 记录响应详情
-		fmt.Printf("收到响应: 状态码=%d\n响应体: %s\n", resp.StatusCode, string(respBody))
+		log.Debug("收到RAGFlow响应", "status", resp.StatusCode, logging.Body("response_body", string(respBody)))
 
-		// 如果状态码是405，尝试切换HTTP方法
-		if resp.StatusCode == http.StatusMethodNotAllowed && i < MaxRetries {
-			fmt.Printf("收到405错误，尝试切换HTTP方法\n")
+		// 如果状态码是405，尝试在POST和GET之间切换HTTP方法
+		if resp.StatusCode == http.StatusMethodNotAllowed && i < conf.maxRetries && (method == "POST" || method == "GET") {
+			log.Warn("收到405错误，尝试切换HTTP方法", "method", method)
 			if method == "POST" {
 				method = "GET"
 			} else {
@@ -430,7 +613,7 @@ func makeHTTPRequestWithRetry(method, url string, body []byte) ([]byte, error) {
 
 		// 检查HTTP状态码，但只记录而不失败，以确保能尽可能返回响应
 		if resp.StatusCode != http.StatusOK {
-			fmt.Printf("警告: HTTP状态码错误: %d\n", resp.StatusCode)
+			log.Warn("RAGFlow返回错误状态码", "status", resp.StatusCode)
 		}
 
 		// 请求成功
@@ -438,5 +621,6 @@ func makeHTTPRequestWithRetry(method, url string, body []byte) ([]byte, error) {
 	}
 
 	// 所有重试都失败了
-	return nil, fmt.Errorf("在%d次尝试后请求失败: %v", MaxRetries+1, lastErr)
+	metrics.RagflowErrors.Inc(endpoint)
+	return nil, fmt.Errorf("在%d次尝试后请求失败: %v", conf.maxRetries+1, lastErr)
 }
@@ -0,0 +1,243 @@
+package ragflow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+)
+
+// StreamEvent 是RAGFlow流式接口返回的一个SSE事件
+type StreamEvent struct {
+	Code      int             // 业务状态码，0表示成功
+	Message   string          // 错误信息
+	Answer    string          // 本次事件中的答案(RAGFlow返回截至目前的完整答案)
+	SessionID string          // 会话ID
+	Reference json.RawMessage // 引用信息，原样保留
+	Done      bool            // 是否为结束事件
+}
+
+// 流式响应中单个事件的JSON结构
+type streamPayload struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type streamData struct {
+	Answer    string          `json:"answer"`
+	SessionID string          `json:"session_id"`
+	Reference json.RawMessage `json:"reference"`
+}
+
+// ParseSSEStream 逐个解析SSE事件并回调，handle 返回错误时停止解析
+func ParseSSEStream(r io.Reader, handle func(StreamEvent) error) error {
+	reader := bufio.NewReader(r)
+	var dataLines []string
+
+	dispatch := func() error {
+		if len(dataLines) == 0 {
+			return nil
+		}
+		data := strings.Join(dataLines, "\n")
+		dataLines = dataLines[:0]
+
+		event, err := parseStreamEvent(data)
+		if err != nil {
+			return err
+		}
+		return handle(event)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case trimmed == "":
+			// 空行表示一个事件结束
+			if dispatchErr := dispatch(); dispatchErr != nil {
+				return dispatchErr
+			}
+		case strings.HasPrefix(trimmed, ":"):
+			// 注释行(心跳)
+		case strings.HasPrefix(trimmed, "data:"):
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(trimmed, "data:"), " "))
+		default:
+			// event:、id:、retry: 等字段不影响答案
+		}
+
+		if err == io.EOF {
+			return dispatch()
+		}
+	}
+}
+
+// parseStreamEvent 解析单个事件的data内容
+func parseStreamEvent(data string) (StreamEvent, error) {
+	if data == "[DONE]" {
+		return StreamEvent{Done: true}, nil
+	}
+
+	var payload streamPayload
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return StreamEvent{}, fmt.Errorf("解析流式事件失败: %w", err)
+	}
+
+	event := StreamEvent{Code: payload.Code, Message: payload.Message}
+
+	// 结束事件的 data 为 true
+	trimmed := bytes.TrimSpace(payload.Data)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("true")) || bytes.Equal(trimmed, []byte("null")) {
+		event.Done = payload.Code == 0
+		return event, nil
+	}
+
+	var d streamData
+	if err := json.Unmarshal(trimmed, &d); err != nil {
+		return StreamEvent{}, fmt.Errorf("解析流式事件数据失败: %w", err)
+	}
+	event.Answer = d.Answer
+	event.SessionID = d.SessionID
+	event.Reference = d.Reference
+	return event, nil
+}
+
+// CompleteStream 使用流式接口获取答案，每收到新内容时以当前完整答案调用 onPartial
+func (c *Client) CompleteStream(ctx context.Context, question, userID string, onPartial func(answer string)) (string, *Reference, error) {
+	conf := c.config()
+	log := logging.FromContext(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, 140*time.Second)
+	defer cancel()
+
+	sessionID, err := c.ensureSession(ctx, userID)
+	if err != nil {
+		log.Error("创建会话失败", "error", err)
+		return "", nil, err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/chats/%s/completions", conf.baseURL, c.chat(conf))
+	reqBody := map[string]interface{}{
+		"question":   question,
+		"session_id": sessionID,
+		"stream":     true,
+	}
+	body, _ := json.Marshal(reqBody)
+
+	log.Info("发送RAGFlow流式查询", "session_id", sessionID)
+
+	resp, err := c.openStream(ctx, url, body)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	answer, reference, err := readStreamAnswer(resp.Body, onPartial)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Warn("RAGFlow流式查询超时")
+			return answer, reference, ctx.Err()
+		}
+		return answer, reference, err
+	}
+
+	if answer == "" {
+		return "", nil, errors.New("无法提取答案")
+	}
+	return answer, reference, nil
+}
+
+// readStreamAnswer 从流式响应中累积答案和引用，每收到新内容时以当前完整答案调用 onPartial，
+// 出错时返回已收到的部分答案
+func readStreamAnswer(r io.Reader, onPartial func(answer string)) (string, *Reference, error) {
+	var answer string
+	var reference *Reference
+	err := ParseSSEStream(r, func(event StreamEvent) error {
+		if event.Code != 0 {
+			return fmt.Errorf("RAGFlow返回错误: %s (代码: %d)", event.Message, event.Code)
+		}
+		if event.Done {
+			return nil
+		}
+		if ref := parseReference(event.Reference); ref != nil {
+			reference = ref
+		}
+		if event.Answer == "" {
+			return nil
+		}
+
+		// RAGFlow每次返回截至目前的完整答案，兼容只返回增量的实现
+		if strings.HasPrefix(event.Answer, answer) {
+			answer = event.Answer
+		} else {
+			answer += event.Answer
+		}
+
+		if onPartial != nil {
+			onPartial(answer)
+		}
+		return nil
+	})
+	return answer, reference, err
+}
+
+// openStream 发起流式请求，连接失败时按配置重试
+func (c *Client) openStream(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	conf := c.config()
+	var lastErr error
+	log := logging.FromContext(ctx).With("endpoint", endpointCompletionStream)
+
+	// 流式请求只统计到收到响应头为止的耗时
+	start := time.Now()
+	defer func() {
+		metrics.RagflowRequestDuration.Observe(time.Since(start).Seconds(), endpointCompletionStream)
+	}()
+
+	for i := 0; i <= conf.maxRetries; i++ {
+		if i > 0 {
+			time.Sleep(conf.retryInterval)
+			metrics.RagflowRetries.Inc(endpointCompletionStream)
+			log.Info("重试RAGFlow流式请求", "retry", i)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+conf.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := c.streamClient.Do(req)
+		if err != nil {
+			lastErr = err
+			log.Warn("RAGFlow流式请求失败", "error", err)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("HTTP状态码错误: %d, 响应体: %s", resp.StatusCode, string(respBody))
+			continue
+		}
+
+		return resp, nil
+	}
+
+	metrics.RagflowErrors.Inc(endpointCompletionStream)
+	return nil, fmt.Errorf("在%d次尝试后请求失败: %v", conf.maxRetries+1, lastErr)
+}
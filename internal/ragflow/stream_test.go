@@ -0,0 +1,159 @@
+package ragflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 录制的RAGFlow流式响应样本，与 scripts/test/fake_ragflow.py 共用
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "scripts", "test", "fixtures", name))
+	if err != nil {
+		t.Fatalf("读取样本失败: %v", err)
+	}
+	return string(data)
+}
+
+func TestParseSSEStreamFixture(t *testing.T) {
+	var events []StreamEvent
+	err := ParseSSEStream(strings.NewReader(readFixture(t, "ragflow_stream.sse")), func(e StreamEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	// 9个答案事件加1个结束事件，心跳注释不产生事件
+	if len(events) != 10 {
+		t.Fatalf("事件数 = %d, 期望 10", len(events))
+	}
+	for i, e := range events[:9] {
+		if e.Code != 0 || e.Done || e.Answer == "" || e.SessionID != "fake_session" {
+			t.Errorf("第%d个事件 = %+v", i, e)
+		}
+	}
+	if last := events[9]; !last.Done || last.Code != 0 {
+		t.Errorf("结束事件 = %+v", last)
+	}
+}
+
+func TestReadStreamAnswerFixture(t *testing.T) {
+	var partials []string
+	answer, reference, err := readStreamAnswer(strings.NewReader(readFixture(t, "ragflow_stream.sse")), func(partial string) {
+		partials = append(partials, partial)
+	})
+	if err != nil {
+		t.Fatalf("读取答案失败: %v", err)
+	}
+
+	if len(partials) != 9 {
+		t.Fatalf("部分答案回调次数 = %d, 期望 9", len(partials))
+	}
+	if partials[0] != "浙江腾视科技有限公司成立" {
+		t.Errorf("首个部分答案 = %q", partials[0])
+	}
+	for i := 1; i < len(partials); i++ {
+		if !strings.HasPrefix(partials[i], partials[i-1]) {
+			t.Errorf("第%d个部分答案没有延续上一个: %q -> %q", i, partials[i-1], partials[i])
+		}
+	}
+
+	if answer != partials[len(partials)-1] {
+		t.Errorf("最终答案与最后一次回调不一致: %q", answer)
+	}
+	if !strings.HasSuffix(answer, "##1$$\n\n核心团队成员来自华为、中兴等知名企业。") {
+		t.Errorf("最终答案 = %q", answer)
+	}
+
+	if reference == nil {
+		t.Fatal("没有解析到引用")
+	}
+	if len(reference.Chunks) != 2 {
+		t.Fatalf("引用片段数 = %d, 期望 2", len(reference.Chunks))
+	}
+	if c := reference.Chunks[0]; c.ID != "chunk0" || c.DocumentName != "公司简介.pdf" {
+		t.Errorf("引用片段0 = %+v", c)
+	}
+	if c := reference.Chunks[1]; c.ID != "chunk1" || c.DocumentName != "产品手册.docx" {
+		t.Errorf("引用片段1 = %+v", c)
+	}
+}
+
+func TestReadStreamAnswerErrorFixture(t *testing.T) {
+	var partials []string
+	answer, _, err := readStreamAnswer(strings.NewReader(readFixture(t, "ragflow_stream_error.sse")), func(partial string) {
+		partials = append(partials, partial)
+	})
+	if err == nil {
+		t.Fatal("期望返回RAGFlow错误")
+	}
+	if !strings.Contains(err.Error(), "代码: 500") || !strings.Contains(err.Error(), "LLM服务暂时不可用") {
+		t.Errorf("错误 = %v", err)
+	}
+	// 出错前收到的内容仍然返回
+	if answer != "正在" || len(partials) != 1 {
+		t.Errorf("答案 = %q, 回调 = %q", answer, partials)
+	}
+}
+
+func TestParseSSEStreamTerminators(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		wantAnswer string
+		wantDone   bool
+		wantErr    bool
+	}{
+		{
+			name:       "data:true 结束",
+			stream:     "data:{\"code\": 0, \"data\": {\"answer\": \"你好\"}}\n\ndata:{\"code\": 0, \"data\": true}\n\n",
+			wantAnswer: "你好",
+			wantDone:   true,
+		},
+		{
+			name:       "[DONE] 结束",
+			stream:     "data: {\"code\": 0, \"data\": {\"answer\": \"你好\"}}\n\ndata: [DONE]\n\n",
+			wantAnswer: "你好",
+			wantDone:   true,
+		},
+		{
+			name:       "缺少结束事件和末尾空行",
+			stream:     "data:{\"code\": 0, \"data\": {\"answer\": \"你\"}}\n\ndata:{\"code\": 0, \"data\": {\"answer\": \"你好\"}}",
+			wantAnswer: "你好",
+		},
+		{
+			name:       "事件被截断",
+			stream:     "data:{\"code\": 0, \"data\": {\"answer\": \"你\"}}\n\ndata:{\"code\": 0, \"data\": {\"ans",
+			wantAnswer: "你",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var done bool
+			err := ParseSSEStream(strings.NewReader(tt.stream), func(e StreamEvent) error {
+				done = done || e.Done
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSSEStream 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if done != tt.wantDone {
+				t.Errorf("结束事件 = %v, 期望 %v", done, tt.wantDone)
+			}
+
+			answer, _, err := readStreamAnswer(strings.NewReader(tt.stream), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readStreamAnswer 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if answer != tt.wantAnswer {
+				t.Errorf("答案 = %q, 期望 %q", answer, tt.wantAnswer)
+			}
+		})
+	}
+}
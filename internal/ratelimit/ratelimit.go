@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+)
+
+// 超出限制的原因
+const (
+	ReasonRate  = "rate"  // 提问太频繁，令牌桶已空
+	ReasonDaily = "daily" // 当天的提问次数已用完
+)
+
+// 用户超过该时间没有提问时，清理其计数(令牌桶已恢复满，每日次数也已重置)
+const idleTimeout = 24 * time.Hour
+
+// Result 是一次提问的检查结果
+type Result struct {
+	Allowed bool
+	Reason  string    // 未通过时的原因，见 ReasonRate、ReasonDaily
+	RetryAt time.Time // 未通过时，用户可以再次提问的时间
+	Limit   int       // 未通过时对应的限制次数(每日次数或连续提问次数)
+}
+
+// Limiter 按用户限制提问：令牌桶限制提问频率和连续提问次数，每日次数限制一天内的提问总数。
+// 计数保存在内存中，重启后重新计数
+type Limiter struct {
+	mu          sync.Mutex
+	cfg         config.LimitConfig
+	users       map[string]*userState
+	lastCleanup time.Time
+}
+
+// userState 是一个用户的令牌桶和当天的提问次数
+type userState struct {
+	tokens  float64
+	updated time.Time
+	day     string // 计数所属的日期
+	count   int
+}
+
+// New 按配置创建限制器
+func New(cfg config.LimitConfig) *Limiter {
+	return &Limiter{cfg: cfg, users: make(map[string]*userState), lastCleanup: time.Now()}
+}
+
+// UpdateConfig 替换限制配置，已有的计数保留，令牌数不超过新的容量
+func (l *Limiter) UpdateConfig(cfg config.LimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = cfg
+	for _, state := range l.users {
+		if state.tokens > float64(cfg.Burst) {
+			state.tokens = float64(cfg.Burst)
+		}
+	}
+}
+
+// BlockedMessage 返回回复黑名单用户的消息，为空时不回复
+func (l *Limiter) BlockedMessage() string {
+	if l == nil {
+		return ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.BlockedMessage
+}
+
+// Allow 检查用户是否可以提问，通过时消耗一次提问次数
+func (l *Limiter) Allow(userID string) Result {
+	return l.allow(userID, time.Now())
+}
+
+func (l *Limiter) allow(userID string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cfg := l.cfg
+	if !cfg.Enabled {
+		return Result{Allowed: true}
+	}
+	l.cleanupLocked(now)
+
+	today := now.Format("2006-01-02")
+	state, ok := l.users[userID]
+	if !ok {
+		state = &userState{tokens: float64(cfg.Burst), updated: now, day: today}
+		l.users[userID] = state
+	}
+
+	// 按经过的时间恢复令牌，每日次数在日期变化后重置
+	if cfg.PerMinute > 0 {
+		state.tokens += now.Sub(state.updated).Minutes() * float64(cfg.PerMinute)
+		if state.tokens > float64(cfg.Burst) {
+			state.tokens = float64(cfg.Burst)
+		}
+	}
+	state.updated = now
+	if state.day != today {
+		state.day, state.count = today, 0
+	}
+
+	if cfg.DailyQuota > 0 && state.count >= cfg.DailyQuota {
+		year, month, day := now.Date()
+		tomorrow := time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+		return Result{Reason: ReasonDaily, RetryAt: tomorrow, Limit: cfg.DailyQuota}
+	}
+	if cfg.PerMinute > 0 && state.tokens < 1 {
+		wait := time.Duration((1 - state.tokens) / float64(cfg.PerMinute) * float64(time.Minute))
+		return Result{Reason: ReasonRate, RetryAt: now.Add(wait), Limit: cfg.Burst}
+	}
+
+	if cfg.PerMinute > 0 {
+		state.tokens--
+	}
+	state.count++
+	return Result{Allowed: true}
+}
+
+// cleanupLocked 定期删除长时间没有提问的用户，调用方需持有锁
+func (l *Limiter) cleanupLocked(now time.Time) {
+	if now.Sub(l.lastCleanup) < 10*time.Minute {
+		return
+	}
+	l.lastCleanup = now
+
+	for userID, state := range l.users {
+		if now.Sub(state.updated) > idleTimeout {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// Len 返回当前有计数的用户数
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.users)
+}
@@ -0,0 +1,261 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind 是个人信息的类型
+type Kind string
+
+const (
+	Phone    Kind = "手机号"
+	IDCard   Kind = "身份证"
+	BankCard Kind = "银行卡"
+	Email    Kind = "邮箱"
+)
+
+var (
+	// 数字串，允许以单个空格或短横线分组(如 138 1234 5678、6222-0212-3456-7890)，身份证末位可为X
+	numberPattern = regexp.MustCompile(`\+?\d(?:[ -]?\d)*(?:[ -]?[\dXx])?`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+)
+
+// Match 是文本中一处个人信息，Start、End 为字节偏移
+type Match struct {
+	Kind  Kind
+	Start int
+	End   int
+	Value string
+}
+
+// Find 找出文本中的手机号、身份证号(校验码正确)、银行卡号(Luhn校验通过)和邮箱，按出现顺序返回
+func Find(text string) []Match {
+	var matches []Match
+	for _, loc := range emailPattern.FindAllStringIndex(text, -1) {
+		matches = append(matches, Match{Kind: Email, Start: loc[0], End: loc[1], Value: text[loc[0]:loc[1]]})
+	}
+
+	for _, loc := range numberPattern.FindAllStringIndex(text, -1) {
+		// 与字母相连的数字串(如订单号、邮箱)不处理
+		if adjoinsWord(text, loc[0], loc[1]) || overlaps(matches, loc[0], loc[1]) {
+			continue
+		}
+		if kind, ok := classify(text[loc[0]:loc[1]]); ok {
+			matches = append(matches, Match{Kind: kind, Start: loc[0], End: loc[1], Value: text[loc[0]:loc[1]]})
+			continue
+		}
+
+		// 整体不是个人信息时，按分隔符拆开逐段识别，如用空格隔开的两个手机号
+		start := loc[0]
+		for _, part := range strings.FieldsFunc(text[loc[0]:loc[1]], isSeparator) {
+			offset := strings.Index(text[start:loc[1]], part) + start
+			start = offset + len(part)
+			if kind, ok := classify(part); ok {
+				matches = append(matches, Match{Kind: kind, Start: offset, End: start, Value: part})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// classify 判断数字串的类型
+func classify(value string) (Kind, bool) {
+	digits := strings.Map(func(r rune) rune {
+		if isSeparator(r) {
+			return -1
+		}
+		return r
+	}, value)
+
+	international := strings.HasPrefix(digits, "+")
+	digits = strings.TrimPrefix(digits, "+")
+	if international || (len(digits) == 13 && strings.HasPrefix(digits, "86")) {
+		digits = strings.TrimPrefix(digits, "86")
+		if isMobile(digits) {
+			return Phone, true
+		}
+		return "", false
+	}
+
+	switch {
+	case isMobile(digits):
+		return Phone, true
+	case isIDCard(digits):
+		return IDCard, true
+	case isBankCard(digits):
+		return BankCard, true
+	}
+	return "", false
+}
+
+// isMobile 判断是否为11位的大陆手机号
+func isMobile(digits string) bool {
+	return len(digits) == 11 && digits[0] == '1' && digits[1] >= '3' && digits[1] <= '9' && allDigits(digits)
+}
+
+// 身份证校验码的加权因子和校验码
+var (
+	idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	idCardChecks  = "10X98765432"
+)
+
+// isIDCard 判断是否为18位身份证号，按 GB 11643 校验末位校验码
+func isIDCard(value string) bool {
+	if len(value) != 18 || !allDigits(value[:17]) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(value[i]-'0') * idCardWeights[i]
+	}
+	return strings.ToUpper(value[17:]) == string(idCardChecks[sum%11])
+}
+
+// isBankCard 判断是否为13到19位且通过 Luhn 校验的银行卡号
+func isBankCard(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 || !allDigits(digits) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isSeparator(r rune) bool {
+	return r == ' ' || r == '-'
+}
+
+// adjoinsWord 判断 [start, end) 前后是否紧挨着英文字母、数字或下划线
+func adjoinsWord(text string, start, end int) bool {
+	isWord := func(c byte) bool {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+	}
+	return start > 0 && isWord(text[start-1]) || end < len(text) && isWord(text[end])
+}
+
+func overlaps(matches []Match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && m.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask 屏蔽文本中的个人信息，用于日志：手机号保留前3位和后4位，身份证和银行卡保留后4位，邮箱保留首字符和域名
+func Mask(text string) string {
+	matches := Find(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(maskValue(m))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func maskValue(m Match) string {
+	switch m.Kind {
+	case Email:
+		at := strings.LastIndex(m.Value, "@")
+		return m.Value[:1] + "***" + m.Value[at:]
+	case Phone:
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, m.Value)
+		return digits[len(digits)-11:len(digits)-8] + "****" + digits[len(digits)-4:]
+	default:
+		value := strings.Map(func(r rune) rune {
+			if isSeparator(r) {
+				return -1
+			}
+			return r
+		}, m.Value)
+		return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+	}
+}
+
+// Placeholders 把文本中的个人信息替换为占位符，并记录占位符对应的原文，用于还原答案
+type Placeholders struct {
+	values map[string]string // 占位符 -> 原文
+	counts map[Kind]int
+}
+
+// Replace 把文本中的个人信息替换为 [手机号1]、[身份证1] 这样的占位符，相同的内容使用相同的占位符
+func (p *Placeholders) Replace(text string) string {
+	matches := Find(text)
+	if len(matches) == 0 {
+		return text
+	}
+	if p.values == nil {
+		p.values = make(map[string]string)
+		p.counts = make(map[Kind]int)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(p.placeholder(m))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (p *Placeholders) placeholder(m Match) string {
+	for placeholder, value := range p.values {
+		if value == m.Value {
+			return placeholder
+		}
+	}
+	p.counts[m.Kind]++
+	placeholder := fmt.Sprintf("[%s%d]", m.Kind, p.counts[m.Kind])
+	p.values[placeholder] = m.Value
+	return placeholder
+}
+
+// Len 返回已替换的个人信息数量
+func (p *Placeholders) Len() int {
+	return len(p.values)
+}
+
+// Restore 把答案中的占位符还原为原文
+func (p *Placeholders) Restore(text string) string {
+	for placeholder, value := range p.values {
+		text = strings.ReplaceAll(text, placeholder, value)
+	}
+	return text
+}
@@ -0,0 +1,372 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bolt 存储使用的 bucket
+var (
+	bucketMessages    = []byte("messages")     // 消息ID -> 过期时间
+	bucketAnswers     = []byte("answers")      // 消息ID -> 答案记录
+	bucketUserAnswers = []byte("user_answers") // 用户ID -> 最近一条消息ID
+	bucketSessions    = []byte("sessions")     // 用户ID -> 会话
+	bucketUserAccess  = []byte("user_access")  // 用户ID -> 黑白名单状态
+)
+
+// BoltStore 是基于 bbolt 的本地文件存储，重启后数据不丢失
+type BoltStore struct {
+	db         *bolt.DB
+	answerTTL  time.Duration
+	sessionTTL time.Duration
+	stop       chan struct{}
+}
+
+type boltAnswer struct {
+	Answer    Answer    `json:"answer"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type boltSession struct {
+	SessionID string    `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewBoltStore 打开(或创建)本地数据文件，并按 cleanupInterval 定期清理过期数据
+func NewBoltStore(path string, answerTTL, sessionTTL, cleanupInterval time.Duration) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开存储文件失败: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMessages, bucketAnswers, bucketUserAnswers, bucketSessions, bucketUserAccess} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化存储失败: %w", err)
+	}
+
+	s := &BoltStore{
+		db:         db,
+		answerTTL:  answerTTL,
+		sessionTTL: sessionTTL,
+		stop:       make(chan struct{}),
+	}
+
+	go runCleanup(cleanupInterval, s.stop, s.cleanup)
+	return s, nil
+}
+
+// MarkMessage 记录消息ID，消息首次出现时返回 true
+func (s *BoltStore) MarkMessage(msgID string) (bool, error) {
+	isNew := false
+	now := time.Now()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketMessages)
+		if v := b.Get([]byte(msgID)); v != nil {
+			var expiresAt time.Time
+			if err := expiresAt.UnmarshalText(v); err == nil && now.Before(expiresAt) {
+				return nil
+			}
+		}
+
+		isNew = true
+		v, _ := now.Add(s.answerTTL).MarshalText()
+		return b.Put([]byte(msgID), v)
+	})
+	return isNew, err
+}
+
+// SaveAnswer 保存答案记录
+func (s *BoltStore) SaveAnswer(answer *Answer) error {
+	data, err := json.Marshal(boltAnswer{Answer: *answer, ExpiresAt: time.Now().Add(s.answerTTL)})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAnswers)
+		exists := b.Get([]byte(answer.MsgID)) != nil
+		if err := b.Put([]byte(answer.MsgID), data); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		return tx.Bucket(bucketUserAnswers).Put([]byte(answer.UserID), []byte(answer.MsgID))
+	})
+}
+
+// GetAnswer 按消息ID查询答案记录
+func (s *BoltStore) GetAnswer(msgID string) (*Answer, bool, error) {
+	var answer *Answer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		answer, err = getBoltAnswer(tx, msgID)
+		return err
+	})
+	return answer, answer != nil, err
+}
+
+// GetUserAnswer 查询用户最近一条答案记录
+func (s *BoltStore) GetUserAnswer(userID string) (*Answer, bool, error) {
+	var answer *Answer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		msgID := tx.Bucket(bucketUserAnswers).Get([]byte(userID))
+		if msgID == nil {
+			return nil
+		}
+
+		var err error
+		answer, err = getBoltAnswer(tx, string(msgID))
+		return err
+	})
+	return answer, answer != nil, err
+}
+
+// getBoltAnswer 读取未过期的答案记录，不存在时返回 nil
+func getBoltAnswer(tx *bolt.Tx, msgID string) (*Answer, error) {
+	v := tx.Bucket(bucketAnswers).Get([]byte(msgID))
+	if v == nil {
+		return nil, nil
+	}
+
+	var entry boltAnswer
+	if err := json.Unmarshal(v, &entry); err != nil {
+		return nil, fmt.Errorf("解析答案记录失败: %w", err)
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		return nil, nil
+	}
+	return &entry.Answer, nil
+}
+
+// DeleteUserAnswers 删除用户的全部答案记录
+func (s *BoltStore) DeleteUserAnswers(userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAnswers)
+
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var entry boltAnswer
+			if json.Unmarshal(v, &entry) == nil && entry.Answer.UserID == userID {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketUserAnswers).Delete([]byte(userID))
+	})
+}
+
+// ListAnswers 按创建时间倒序列出答案记录
+func (s *BoltStore) ListAnswers(userID string, limit int) ([]*Answer, error) {
+	now := time.Now()
+
+	var answers []*Answer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAnswers).ForEach(func(k, v []byte) error {
+			var entry boltAnswer
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("解析答案记录失败: %w", err)
+			}
+			if now.After(entry.ExpiresAt) || (userID != "" && entry.Answer.UserID != userID) {
+				return nil
+			}
+			answers = append(answers, &entry.Answer)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortAnswers(answers, limit), nil
+}
+
+// GetUserSession 查询用户对应的会话ID
+func (s *BoltStore) GetUserSession(userID string) (string, bool, error) {
+	var session boltSession
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketSessions).Get([]byte(userID))
+		if v == nil {
+			return nil
+		}
+		if err := json.Unmarshal(v, &session); err != nil {
+			return fmt.Errorf("解析会话记录失败: %w", err)
+		}
+		found = time.Now().Before(session.ExpiresAt)
+		return nil
+	})
+	if err != nil || !found {
+		return "", false, err
+	}
+	return session.SessionID, true, nil
+}
+
+// SaveUserSession 保存用户对应的会话ID
+func (s *BoltStore) SaveUserSession(userID, sessionID string) error {
+	data, err := json.Marshal(boltSession{SessionID: sessionID, ExpiresAt: time.Now().Add(s.sessionTTL)})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Put([]byte(userID), data)
+	})
+}
+
+// DeleteUserSession 删除用户对应的会话ID
+func (s *BoltStore) DeleteUserSession(userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Delete([]byte(userID))
+	})
+}
+
+// GetUserKnowledgeBase 查询用户选择的知识库名称
+func (s *BoltStore) GetUserKnowledgeBase(userID string) (string, bool, error) {
+	return s.GetUserSession(knowledgeBaseKey(userID))
+}
+
+// SaveUserKnowledgeBase 保存用户选择的知识库名称
+func (s *BoltStore) SaveUserKnowledgeBase(userID, name string) error {
+	return s.SaveUserSession(knowledgeBaseKey(userID), name)
+}
+
+// DeleteUserKnowledgeBase 删除用户选择的知识库
+func (s *BoltStore) DeleteUserKnowledgeBase(userID string) error {
+	return s.DeleteUserSession(knowledgeBaseKey(userID))
+}
+
+// GetUserAccess 查询用户在黑白名单中的状态
+func (s *BoltStore) GetUserAccess(userID string) (string, bool, error) {
+	var access string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		access = string(tx.Bucket(bucketUserAccess).Get([]byte(userID)))
+		return nil
+	})
+	return access, access != "", err
+}
+
+// SetUserAccess 把用户加入黑名单或白名单
+func (s *BoltStore) SetUserAccess(userID, access string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUserAccess).Put([]byte(userID), []byte(access))
+	})
+}
+
+// DeleteUserAccess 把用户移出黑白名单
+func (s *BoltStore) DeleteUserAccess(userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUserAccess).Delete([]byte(userID))
+	})
+}
+
+// ListUserAccess 列出黑白名单中的全部用户
+func (s *BoltStore) ListUserAccess() (map[string]string, error) {
+	list := make(map[string]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUserAccess).ForEach(func(k, v []byte) error {
+			list[string(k)] = string(v)
+			return nil
+		})
+	})
+	return list, err
+}
+
+// Stats 返回各类记录的条数
+func (s *BoltStore) Stats() (Stats, error) {
+	var stats Stats
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats.Messages = tx.Bucket(bucketMessages).Stats().KeyN
+		stats.Answers = tx.Bucket(bucketAnswers).Stats().KeyN
+		stats.Sessions = tx.Bucket(bucketSessions).Stats().KeyN
+		return nil
+	})
+	return stats, err
+}
+
+// Close 停止后台清理并关闭数据文件
+func (s *BoltStore) Close() error {
+	close(s.stop)
+	return s.db.Close()
+}
+
+// cleanup 删除已过期的数据
+func (s *BoltStore) cleanup() {
+	now := time.Now()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// bbolt 不允许在遍历时删除，先收集过期的key
+		var expiredMessages, expiredAnswers, expiredSessions [][]byte
+		expiredUsers := make(map[string]string)
+
+		tx.Bucket(bucketMessages).ForEach(func(k, v []byte) error {
+			var expiresAt time.Time
+			if expiresAt.UnmarshalText(v) != nil || now.After(expiresAt) {
+				expiredMessages = append(expiredMessages, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		tx.Bucket(bucketAnswers).ForEach(func(k, v []byte) error {
+			var entry boltAnswer
+			if json.Unmarshal(v, &entry) != nil || now.After(entry.ExpiresAt) {
+				expiredAnswers = append(expiredAnswers, append([]byte(nil), k...))
+				expiredUsers[entry.Answer.UserID] = string(k)
+			}
+			return nil
+		})
+		tx.Bucket(bucketSessions).ForEach(func(k, v []byte) error {
+			var session boltSession
+			if json.Unmarshal(v, &session) != nil || now.After(session.ExpiresAt) {
+				expiredSessions = append(expiredSessions, append([]byte(nil), k...))
+			}
+			return nil
+		})
+
+		for _, k := range expiredMessages {
+			tx.Bucket(bucketMessages).Delete(k)
+		}
+		for _, k := range expiredAnswers {
+			tx.Bucket(bucketAnswers).Delete(k)
+		}
+		for userID, msgID := range expiredUsers {
+			if string(tx.Bucket(bucketUserAnswers).Get([]byte(userID))) == msgID {
+				tx.Bucket(bucketUserAnswers).Delete([]byte(userID))
+			}
+		}
+		for _, k := range expiredSessions {
+			tx.Bucket(bucketSessions).Delete(k)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("清理过期数据失败", "error", err)
+	}
+}
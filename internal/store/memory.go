@@ -0,0 +1,282 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore 是带TTL过期清理的内存存储，重启后数据丢失
+type MemoryStore struct {
+	answerTTL  time.Duration
+	sessionTTL time.Duration
+
+	mu          sync.RWMutex
+	messages    map[string]time.Time     // 消息ID -> 过期时间
+	answers     map[string]*memoryAnswer // 消息ID -> 答案记录
+	userAnswers map[string]string        // 用户ID -> 最近一条消息ID
+	sessions    map[string]memorySession // 用户ID -> 会话
+	access      map[string]string        // 用户ID -> 黑白名单状态
+
+	stop chan struct{}
+}
+
+type memoryAnswer struct {
+	answer    Answer
+	expiresAt time.Time
+}
+
+type memorySession struct {
+	sessionID string
+	expiresAt time.Time
+}
+
+// NewMemoryStore 创建内存存储，并按 cleanupInterval 定期清理过期数据
+func NewMemoryStore(answerTTL, sessionTTL, cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		answerTTL:   answerTTL,
+		sessionTTL:  sessionTTL,
+		messages:    make(map[string]time.Time),
+		answers:     make(map[string]*memoryAnswer),
+		userAnswers: make(map[string]string),
+		sessions:    make(map[string]memorySession),
+		access:      make(map[string]string),
+		stop:        make(chan struct{}),
+	}
+
+	go runCleanup(cleanupInterval, s.stop, s.cleanup)
+	return s
+}
+
+// MarkMessage 记录消息ID，消息首次出现时返回 true
+func (s *MemoryStore) MarkMessage(msgID string) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expiresAt, ok := s.messages[msgID]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.messages[msgID] = now.Add(s.answerTTL)
+	return true, nil
+}
+
+// SaveAnswer 保存答案记录
+func (s *MemoryStore) SaveAnswer(answer *Answer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.answers[answer.MsgID]; !exists {
+		s.userAnswers[answer.UserID] = answer.MsgID
+	}
+	s.answers[answer.MsgID] = &memoryAnswer{
+		answer:    *answer,
+		expiresAt: time.Now().Add(s.answerTTL),
+	}
+	return nil
+}
+
+// GetAnswer 按消息ID查询答案记录
+func (s *MemoryStore) GetAnswer(msgID string) (*Answer, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getAnswerLocked(msgID)
+}
+
+// GetUserAnswer 查询用户最近一条答案记录
+func (s *MemoryStore) GetUserAnswer(userID string) (*Answer, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msgID, ok := s.userAnswers[userID]
+	if !ok {
+		return nil, false, nil
+	}
+	return s.getAnswerLocked(msgID)
+}
+
+func (s *MemoryStore) getAnswerLocked(msgID string) (*Answer, bool, error) {
+	entry, ok := s.answers[msgID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false, nil
+	}
+
+	answer := entry.answer
+	return &answer, true, nil
+}
+
+// DeleteUserAnswers 删除用户的全部答案记录
+func (s *MemoryStore) DeleteUserAnswers(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for msgID, entry := range s.answers {
+		if entry.answer.UserID == userID {
+			delete(s.answers, msgID)
+		}
+	}
+	delete(s.userAnswers, userID)
+	return nil
+}
+
+// ListAnswers 按创建时间倒序列出答案记录
+func (s *MemoryStore) ListAnswers(userID string, limit int) ([]*Answer, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var answers []*Answer
+	for _, entry := range s.answers {
+		if now.After(entry.expiresAt) || (userID != "" && entry.answer.UserID != userID) {
+			continue
+		}
+		answer := entry.answer
+		answers = append(answers, &answer)
+	}
+	return sortAnswers(answers, limit), nil
+}
+
+// GetUserSession 查询用户对应的会话ID
+func (s *MemoryStore) GetUserSession(userID string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[userID]
+	if !ok || time.Now().After(session.expiresAt) {
+		return "", false, nil
+	}
+	return session.sessionID, true, nil
+}
+
+// SaveUserSession 保存用户对应的会话ID
+func (s *MemoryStore) SaveUserSession(userID, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = memorySession{
+		sessionID: sessionID,
+		expiresAt: time.Now().Add(s.sessionTTL),
+	}
+	return nil
+}
+
+// DeleteUserSession 删除用户对应的会话ID
+func (s *MemoryStore) DeleteUserSession(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+	return nil
+}
+
+// GetUserKnowledgeBase 查询用户选择的知识库名称
+func (s *MemoryStore) GetUserKnowledgeBase(userID string) (string, bool, error) {
+	return s.GetUserSession(knowledgeBaseKey(userID))
+}
+
+// SaveUserKnowledgeBase 保存用户选择的知识库名称
+func (s *MemoryStore) SaveUserKnowledgeBase(userID, name string) error {
+	return s.SaveUserSession(knowledgeBaseKey(userID), name)
+}
+
+// DeleteUserKnowledgeBase 删除用户选择的知识库
+func (s *MemoryStore) DeleteUserKnowledgeBase(userID string) error {
+	return s.DeleteUserSession(knowledgeBaseKey(userID))
+}
+
+// GetUserAccess 查询用户在黑白名单中的状态
+func (s *MemoryStore) GetUserAccess(userID string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	access, ok := s.access[userID]
+	return access, ok, nil
+}
+
+// SetUserAccess 把用户加入黑名单或白名单
+func (s *MemoryStore) SetUserAccess(userID, access string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.access[userID] = access
+	return nil
+}
+
+// DeleteUserAccess 把用户移出黑白名单
+func (s *MemoryStore) DeleteUserAccess(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.access, userID)
+	return nil
+}
+
+// ListUserAccess 列出黑白名单中的全部用户
+func (s *MemoryStore) ListUserAccess() (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make(map[string]string, len(s.access))
+	for userID, access := range s.access {
+		list[userID] = access
+	}
+	return list, nil
+}
+
+// Stats 返回各类记录的条数
+func (s *MemoryStore) Stats() (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Stats{Messages: len(s.messages), Answers: len(s.answers), Sessions: len(s.sessions)}, nil
+}
+
+// Close 停止后台清理
+func (s *MemoryStore) Close() error {
+	close(s.stop)
+	return nil
+}
+
+// cleanup 删除已过期的数据
+func (s *MemoryStore) cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for msgID, expiresAt := range s.messages {
+		if now.After(expiresAt) {
+			delete(s.messages, msgID)
+		}
+	}
+	for msgID, entry := range s.answers {
+		if now.After(entry.expiresAt) {
+			delete(s.answers, msgID)
+			if s.userAnswers[entry.answer.UserID] == msgID {
+				delete(s.userAnswers, entry.answer.UserID)
+			}
+		}
+	}
+	for userID, session := range s.sessions {
+		if now.After(session.expiresAt) {
+			delete(s.sessions, userID)
+		}
+	}
+}
+
+// runCleanup 定期执行清理函数，直到 stop 被关闭
+func runCleanup(interval time.Duration, stop <-chan struct{}, cleanup func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cleanup()
+		case <-stop:
+			return
+		}
+	}
+}
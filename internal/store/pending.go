@@ -0,0 +1,47 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SavePending 把停止服务时仍未完成的答案记录写入文件，供下次启动时继续处理
+func SavePending(path string, answers []*Answer) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(answers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再改名，避免停止过程中被中断留下不完整的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadPending 读取上次停止时保存的未完成答案记录并删除文件，文件不存在时返回空列表
+func LoadPending(path string) ([]*Answer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []*Answer
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return answers, os.Remove(path)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+)
+
+// 默认的过期和清理时间
+const (
+	defaultAnswerTTL       = 24 * time.Hour
+	defaultSessionTTL      = 7 * 24 * time.Hour
+	defaultCleanupInterval = 10 * time.Minute
+)
+
+// Answer 是一条用户消息及其答案的处理记录
+type Answer struct {
+	MsgID         string    `json:"msg_id"`         // 消息ID
+	UserID        string    `json:"user_id"`        // 用户ID
+	Account       string    `json:"account"`        // 消息所属的公众号账号名称(企业微信为 wecom)
+	Question      string    `json:"question"`       // 用户的问题
+	Answer        string    `json:"answer"`         // 准备好的答案
+	IsReady       bool      `json:"is_ready"`       // 答案是否准备好
+	ProcessingMsg string    `json:"processing_msg"` // 处理中的消息
+	Pushed        bool      `json:"pushed"`         // 答案是否已通过客服消息推送
+	Sources       string    `json:"sources"`        // 答案的完整引用列表，供 /sources 查看
+	CreatedAt     time.Time `json:"created_at"`     // 创建时间
+}
+
+// 用户在黑白名单中的状态
+const (
+	AccessBlock = "block" // 黑名单，不处理该用户的消息
+	AccessAllow = "allow" // 白名单，不受提问频率和每日次数限制
+)
+
+// Stats 是存储中各类记录的条数
+type Stats struct {
+	Messages int // 消息排重记录
+	Answers  int // 答案记录
+	Sessions int // 会话映射(含知识库选择)
+}
+
+// Store 保存答案、消息排重记录和用户会话映射
+type Store interface {
+	// MarkMessage 记录消息ID用于排重，消息首次出现时返回 true
+	MarkMessage(msgID string) (bool, error)
+
+	// SaveAnswer 保存答案记录，首次保存时将其设为该用户的最近一条记录
+	SaveAnswer(answer *Answer) error
+	// GetAnswer 按消息ID查询答案记录
+	GetAnswer(msgID string) (*Answer, bool, error)
+	// GetUserAnswer 查询用户最近一条答案记录
+	GetUserAnswer(userID string) (*Answer, bool, error)
+	// DeleteUserAnswers 删除用户的全部答案记录
+	DeleteUserAnswers(userID string) error
+	// ListAnswers 按创建时间倒序列出答案记录，userID 为空时列出所有用户，limit<=0 时不限制数量
+	ListAnswers(userID string, limit int) ([]*Answer, error)
+
+	// GetUserSession 查询用户对应的RAGFlow会话ID
+	GetUserSession(userID string) (string, bool, error)
+	// SaveUserSession 保存用户对应的RAGFlow会话ID
+	SaveUserSession(userID, sessionID string) error
+	// DeleteUserSession 删除用户对应的RAGFlow会话ID
+	DeleteUserSession(userID string) error
+
+	// GetUserKnowledgeBase 查询用户通过 /kb 选择的知识库名称
+	GetUserKnowledgeBase(userID string) (string, bool, error)
+	// SaveUserKnowledgeBase 保存用户选择的知识库名称
+	SaveUserKnowledgeBase(userID, name string) error
+	// DeleteUserKnowledgeBase 删除用户选择的知识库，恢复自动路由
+	DeleteUserKnowledgeBase(userID string) error
+
+	// GetUserAccess 查询用户在黑白名单中的状态，见 AccessBlock、AccessAllow
+	GetUserAccess(userID string) (string, bool, error)
+	// SetUserAccess 把用户加入黑名单或白名单，黑白名单不会过期
+	SetUserAccess(userID, access string) error
+	// DeleteUserAccess 把用户移出黑白名单
+	DeleteUserAccess(userID string) error
+	// ListUserAccess 列出黑白名单中的全部用户，返回 用户ID -> 状态
+	ListUserAccess() (map[string]string, error)
+
+	// Stats 返回各类记录的条数(可能包含尚未清理的过期记录)
+	Stats() (Stats, error)
+
+	// Close 停止后台清理并释放资源
+	Close() error
+}
+
+// New 根据配置创建存储，type 为 memory(默认) 或 bolt
+func New(cfg config.StoreConfig) (Store, error) {
+	answerTTL := secondsOrDefault(cfg.AnswerTTL, defaultAnswerTTL)
+	sessionTTL := secondsOrDefault(cfg.SessionTTL, defaultSessionTTL)
+	cleanupInterval := secondsOrDefault(cfg.CleanupInterval, defaultCleanupInterval)
+
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryStore(answerTTL, sessionTTL, cleanupInterval), nil
+	case "bolt":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("bolt 存储需要配置 path")
+		}
+		return NewBoltStore(cfg.Path, answerTTL, sessionTTL, cleanupInterval)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Type)
+	}
+}
+
+// sortAnswers 按创建时间倒序排列答案记录，并截取前 limit 条
+func sortAnswers(answers []*Answer, limit int) []*Answer {
+	sort.Slice(answers, func(i, j int) bool {
+		return answers[i].CreatedAt.After(answers[j].CreatedAt)
+	})
+	if limit > 0 && len(answers) > limit {
+		answers = answers[:limit]
+	}
+	return answers
+}
+
+// knowledgeBaseKey 返回用户知识库选择在会话映射中的key，与会话一起保存并按会话有效期过期
+func knowledgeBaseKey(userID string) string {
+	return "kb:" + userID
+}
+
+// secondsOrDefault 将秒数配置转换为时长，未配置时使用默认值
+func secondsOrDefault(seconds int, def time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
@@ -0,0 +1,227 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 安全模式下微信推送的加密消息体
+type encryptedRequest struct {
+	ToUserName string `xml:"ToUserName"`
+	Encrypt    string `xml:"Encrypt"`
+}
+
+// getMsgCrypt 返回基于账号配置的消息加解密器
+func (a *Account) getMsgCrypt() (*msgCrypt, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.crypt == nil {
+		mc, err := newMsgCrypt(a.cfg.Token, a.cfg.AppID, a.cfg.EncodingAESKey)
+		if err != nil {
+			return nil, err
+		}
+		a.crypt = mc
+	}
+	return a.crypt, nil
+}
+
+// isEncryptedRequest 判断本次回调是否为安全模式(或兼容模式)下的加密消息
+func isEncryptedRequest(c *gin.Context) bool {
+	return c.Query("encrypt_type") == "aes"
+}
+
+// msgCrypt 实现微信公众号消息加解密(WXBizMsgCrypt)
+type msgCrypt struct {
+	token  string
+	appID  string
+	aesKey []byte
+}
+
+// newMsgCrypt 根据Token、AppID和EncodingAESKey创建消息加解密器
+func newMsgCrypt(token, appID, encodingAESKey string) (*msgCrypt, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, fmt.Errorf("EncodingAESKey 长度应为43位，当前为%d位", len(encodingAESKey))
+	}
+
+	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("EncodingAESKey 解码失败: %w", err)
+	}
+
+	return &msgCrypt{token: token, appID: appID, aesKey: aesKey}, nil
+}
+
+// signature 计算 token、timestamp、nonce、encrypt 字典序拼接后的sha1签名
+func (mc *msgCrypt) signature(timestamp, nonce, encrypt string) string {
+	strs := []string{mc.token, timestamp, nonce, encrypt}
+	sort.Strings(strs)
+
+	h := sha1.New()
+	h.Write([]byte(strings.Join(strs, "")))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// verify 以常量时间比较 msg_signature 与本地计算的签名
+func (mc *msgCrypt) verify(msgSignature, timestamp, nonce, encrypt string) bool {
+	return subtle.ConstantTimeCompare([]byte(mc.signature(timestamp, nonce, encrypt)), []byte(msgSignature)) == 1
+}
+
+// decrypt 解密 Encrypt 字段，返回明文消息XML
+func (mc *msgCrypt) decrypt(encrypt string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, fmt.Errorf("密文base64解码失败: %w", err)
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("密文长度不是块大小的整数倍")
+	}
+
+	block, err := aes.NewCipher(mc.aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, mc.aesKey[:aes.BlockSize]).CryptBlocks(plaintext, ciphertext)
+
+	plaintext, err = pkcs7Unpad(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	// 明文格式: 16字节随机串 + 4字节消息长度(网络字节序) + 消息 + AppID
+	if len(plaintext) < 20 {
+		return nil, errors.New("解密后的消息长度不足")
+	}
+	msgLen := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if msgLen < 0 || 20+msgLen > len(plaintext) {
+		return nil, errors.New("解密后的消息长度字段无效")
+	}
+
+	msg := plaintext[20 : 20+msgLen]
+	appID := string(plaintext[20+msgLen:])
+	if appID != mc.appID {
+		return nil, fmt.Errorf("AppID 不匹配: %s", appID)
+	}
+
+	return msg, nil
+}
+
+// encrypt 加密明文消息，返回base64编码的密文
+func (mc *msgCrypt) encrypt(msg []byte) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(random)
+	lenBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lenBytes, uint32(len(msg)))
+	buf.Write(lenBytes)
+	buf.Write(msg)
+	buf.WriteString(mc.appID)
+
+	plaintext := pkcs7Pad(buf.Bytes())
+
+	block, err := aes.NewCipher(mc.aesKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, mc.aesKey[:aes.BlockSize]).CryptBlocks(ciphertext, plaintext)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptRequest 校验 msg_signature 并解密微信推送的消息体
+func (mc *msgCrypt) decryptRequest(body []byte, msgSignature, timestamp, nonce string) ([]byte, error) {
+	var req encryptedRequest
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("加密消息XML解析失败: %w", err)
+	}
+	if req.Encrypt == "" {
+		return nil, errors.New("消息中缺少 Encrypt 字段")
+	}
+
+	if !mc.verify(msgSignature, timestamp, nonce, req.Encrypt) {
+		return nil, errors.New("msg_signature 校验失败")
+	}
+
+	return mc.decrypt(req.Encrypt)
+}
+
+// encryptResponse 加密被动回复消息，返回可直接返回给微信的XML
+func (mc *msgCrypt) encryptResponse(replyXML, nonce string) (string, error) {
+	encrypt, err := mc.encrypt([]byte(replyXML))
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	xmlFormat := `<xml>
+<Encrypt><![CDATA[%s]]></Encrypt>
+<MsgSignature><![CDATA[%s]]></MsgSignature>
+<TimeStamp>%s</TimeStamp>
+<Nonce><![CDATA[%s]]></Nonce>
+</xml>`
+
+	return fmt.Sprintf(xmlFormat, encrypt, mc.signature(timestamp, nonce, encrypt), timestamp, nonce), nil
+}
+
+// pkcs7Pad 按32字节块大小进行PKCS#7填充(微信加解密方案的约定)
+func pkcs7Pad(data []byte) []byte {
+	const blockSize = 32
+	padding := blockSize - len(data)%blockSize
+	return append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+// pkcs7Unpad 去除PKCS#7填充
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("填充数据为空")
+	}
+	padding := int(data[len(data)-1])
+	if padding < 1 || padding > 32 || padding > len(data) {
+		return nil, errors.New("PKCS#7填充无效")
+	}
+	return data[:len(data)-padding], nil
+}
+
+// writeWeChatReply 返回被动回复，安全模式下先加密再返回
+func (h *Handler) writeWeChatReply(c *gin.Context, xmlResponse string) {
+	if isEncryptedRequest(c) {
+		mc, err := h.account.getMsgCrypt()
+		if err == nil {
+			xmlResponse, err = mc.encryptResponse(xmlResponse, c.Query("nonce"))
+		}
+		if err != nil {
+			slog.Error("加密回复消息失败", "account", h.account.Name, "error", err)
+			// 返回success，微信不会向用户展示错误
+			c.String(http.StatusOK, "success")
+			return
+		}
+	}
+
+	c.String(http.StatusOK, xmlResponse)
+}
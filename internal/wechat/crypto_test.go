@@ -0,0 +1,140 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// Token、EncodingAESKey、随机串、nonce 和时间戳取自微信官方 WXBizMsgCrypt 示例
+const (
+	sampleToken          = "spamtest"
+	sampleEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	sampleAppID          = "wx2c2769f8efd9abc2"
+	sampleTimestamp      = "1409304348"
+	sampleNonce          = "1320562132"
+)
+
+// sampleMsg 的密文和签名，由 openssl enc -aes-256-cbc -nopad 按官方方案(16字节随机串 89465c840c5f116f、
+// 网络字节序长度、消息、AppID、32字节块PKCS#7填充)独立生成，不依赖本包的实现
+const (
+	sampleMsg          = "<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName><FromUserName><![CDATA[oyORnuP8q7ou2gfYjqLzSIWZf0rs]]></FromUserName><CreateTime>1409735668</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[你好]]></Content><MsgId>6054768590064713728</MsgId></xml>"
+	sampleEncrypt      = "hyzAe4OzmOMbd6TvGdIOOzC2OXoTKK8eyEB0aCaDrcfV/RxZ8PuEOKmZob+s0t1+WqlTHdIspyv9clkKa2kYdEPCK/CvNJbPf9bu75f0jve450iOMZc/hU9HM9DfxgdHgQ8NrvYtZX6fAc6gn300AVg0juNnpyyk86KOx194KohdeIMdRzlRpp+XjS5ui9uH3oPI2MyFmVM55Q6MClGUCT3zuSxQQzNq/uTgmGrGiwW8SP9NImUpfI5XkX80BYvhIThUhZFoyTXPUO2gUShcEcaQr/KJEMnAyr2Z0STRLuca2ENpSYBeyzhlVSWdjTG7JHJ4NYaEwypEz8i8LS2HzV1W6QGdGwZXisk+rY+D0l/QI+qSbCEcIRDPPiXhCPcjGQC72GIu1y/wTOAvueFQNTWoMINlKPHp7xk1MtWFy9k="
+	sampleMsgSignature = "262906bbee09eb25a37f024d528b191515eb84b8"
+)
+
+func newSampleCrypt(t *testing.T, appID string) *msgCrypt {
+	t.Helper()
+	mc, err := newMsgCrypt(sampleToken, appID, sampleEncodingAESKey)
+	if err != nil {
+		t.Fatalf("创建加解密器失败: %v", err)
+	}
+	return mc
+}
+
+func sampleRequest(encrypt string) []byte {
+	return []byte("<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName><Encrypt><![CDATA[" + encrypt + "]]></Encrypt></xml>")
+}
+
+func TestDecryptRequestKnownAnswer(t *testing.T) {
+	mc := newSampleCrypt(t, sampleAppID)
+
+	if sig := mc.signature(sampleTimestamp, sampleNonce, sampleEncrypt); sig != sampleMsgSignature {
+		t.Errorf("签名 = %s, 期望 %s", sig, sampleMsgSignature)
+	}
+
+	msg, err := mc.decryptRequest(sampleRequest(sampleEncrypt), sampleMsgSignature, sampleTimestamp, sampleNonce)
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if string(msg) != sampleMsg {
+		t.Errorf("明文 = %q, 期望 %q", msg, sampleMsg)
+	}
+}
+
+func TestDecryptRequestBadSignature(t *testing.T) {
+	mc := newSampleCrypt(t, sampleAppID)
+
+	badSignature := strings.Repeat("0", len(sampleMsgSignature))
+	if _, err := mc.decryptRequest(sampleRequest(sampleEncrypt), badSignature, sampleTimestamp, sampleNonce); err == nil {
+		t.Error("错误的 msg_signature 应校验失败")
+	}
+	if _, err := mc.decryptRequest(sampleRequest(sampleEncrypt), sampleMsgSignature, sampleTimestamp, "1"); err == nil {
+		t.Error("nonce 被修改时应校验失败")
+	}
+}
+
+func TestDecryptRejectsOtherAppID(t *testing.T) {
+	mc := newSampleCrypt(t, "wx0000000000000000")
+
+	_, err := mc.decrypt(sampleEncrypt)
+	if err == nil || !strings.Contains(err.Error(), "AppID 不匹配") {
+		t.Errorf("错误 = %v, 期望 AppID 不匹配", err)
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	mc := newSampleCrypt(t, sampleAppID)
+
+	// 随机串、长度和AppID共38字节，26字节的消息恰好需要填充一整块(32字节)，10字节的消息只需填充16字节
+	for _, msg := range []string{"", "a", strings.Repeat("x", 10), strings.Repeat("x", 26), sampleMsg, strings.Repeat("测", 100)} {
+		encrypt, err := mc.encrypt([]byte(msg))
+		if err != nil {
+			t.Fatalf("加密失败: %v", err)
+		}
+		got, err := mc.decrypt(encrypt)
+		if err != nil {
+			t.Fatalf("解密失败(长度 %d): %v", len(msg), err)
+		}
+		if string(got) != msg {
+			t.Errorf("往返结果 = %q, 期望 %q", got, msg)
+		}
+	}
+}
+
+func TestEncryptResponseVerifies(t *testing.T) {
+	mc := newSampleCrypt(t, sampleAppID)
+
+	reply, err := mc.encryptResponse(sampleMsg, sampleNonce)
+	if err != nil {
+		t.Fatalf("加密回复失败: %v", err)
+	}
+
+	var resp struct {
+		Encrypt      string `xml:"Encrypt"`
+		MsgSignature string `xml:"MsgSignature"`
+		TimeStamp    string `xml:"TimeStamp"`
+		Nonce        string `xml:"Nonce"`
+	}
+	if err := xml.Unmarshal([]byte(reply), &resp); err != nil {
+		t.Fatalf("解析加密回复失败: %v", err)
+	}
+	msg, err := mc.decryptRequest([]byte(reply), resp.MsgSignature, resp.TimeStamp, resp.Nonce)
+	if err != nil {
+		t.Fatalf("解密回复失败: %v", err)
+	}
+	if string(msg) != sampleMsg {
+		t.Errorf("回复明文 = %q", msg)
+	}
+}
+
+func TestPKCS7Padding(t *testing.T) {
+	for n := 0; n <= 64; n++ {
+		padded := pkcs7Pad(bytes.Repeat([]byte{'a'}, n))
+		if len(padded)%32 != 0 || len(padded) <= n || len(padded)-n > 32 {
+			t.Fatalf("长度 %d 填充后为 %d", n, len(padded))
+		}
+		unpadded, err := pkcs7Unpad(padded)
+		if err != nil || len(unpadded) != n {
+			t.Fatalf("长度 %d 去除填充后为 %d: %v", n, len(unpadded), err)
+		}
+	}
+
+	if _, err := pkcs7Unpad(append(bytes.Repeat([]byte{'a'}, 31), 33)); err == nil {
+		t.Error("超过32的填充值应视为无效")
+	}
+	if _, err := pkcs7Unpad(nil); err == nil {
+		t.Error("空数据应视为无效")
+	}
+}
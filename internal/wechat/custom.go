@@ -0,0 +1,92 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// 客服消息接口的错误码
+const (
+	errCodeInvalidToken      = 40001 // access_token 无效
+	errCodeTokenExpired      = 42001 // access_token 过期
+	errCodeOutOfTimeLimit    = 45015 // 超出48小时回复时限或用户已取消关注
+	errCodeOutOfCountLimit   = 45047 // 超出客服消息下发条数限制
+	errCodeAPIUnauthorized   = 48001 // 公众号没有客服消息接口权限
+	customSendRequestTimeout = 10 * time.Second
+
+	defaultCustomSendURL = "https://api.weixin.qq.com/cgi-bin/message/custom/send?access_token=%s"
+)
+
+// ErrCustomMessageUnavailable 表示当前无法通过客服消息推送，需要回退到 /status 查询
+var ErrCustomMessageUnavailable = errors.New("客服消息不可用")
+
+// customMessageEnabled 返回是否启用了客服消息推送
+func (a *Account) customMessageEnabled() bool {
+	return a.config().EnableCustomMessage
+}
+
+// SendCustomTextMessage 通过客服消息接口向用户主动推送文本消息
+func (a *Account) SendCustomTextMessage(ctx context.Context, openID, content string) error {
+	if !a.config().EnableCustomMessage {
+		return ErrCustomMessageUnavailable
+	}
+
+	reqBody := map[string]interface{}{
+		"touser":  openID,
+		"msgtype": "text",
+		"text": map[string]string{
+			"content": content,
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	errCode, errMsg, err := a.tokens.post(ctx, a.tokenURL(), func(token string) (int, string, error) {
+		return a.postCustomMessage(token, body)
+	})
+	if err != nil {
+		return err
+	}
+
+	switch errCode {
+	case 0:
+		return nil
+	case errCodeOutOfTimeLimit, errCodeOutOfCountLimit, errCodeAPIUnauthorized:
+		return fmt.Errorf("%w: %d - %s", ErrCustomMessageUnavailable, errCode, errMsg)
+	default:
+		return fmt.Errorf("发送客服消息失败: %d - %s", errCode, errMsg)
+	}
+}
+
+// postCustomMessage 调用客服消息发送接口，返回微信的错误码
+func (a *Account) postCustomMessage(token string, body []byte) (int, string, error) {
+	sendURL := a.config().CustomSendURL
+	if sendURL == "" {
+		sendURL = defaultCustomSendURL
+	}
+	url := fmt.Sprintf(sendURL, token)
+
+	client := &http.Client{Timeout: customSendRequestTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return 0, "", fmt.Errorf("解析客服消息响应失败: %w", err)
+	}
+
+	return result.ErrCode, result.ErrMsg, nil
+}
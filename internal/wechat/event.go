@@ -0,0 +1,91 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/models"
+)
+
+// 默认欢迎语
+const defaultWelcomeMessage = "感谢关注！我是RAG智能问答助手，您可以直接发送问题与我对话，发送 /help 查看可用指令。"
+
+// handleEventMessage 处理微信推送的事件消息
+func (h *Handler) handleEventMessage(ctx context.Context, c *gin.Context, msg models.WeChatMessage) {
+	log := logging.FromContext(ctx)
+	userID := msg.FromUserName
+	log.Info("用户事件", "user_id", userID, "event", msg.Event, "event_key", msg.EventKey)
+
+	switch msg.Event {
+	case "subscribe":
+		// 扫描带参数二维码关注时，EventKey 为 qrscene_ 前缀加场景值，配置了场景动作时直接执行该动作
+		if scene := strings.TrimPrefix(msg.EventKey, "qrscene_"); scene != msg.EventKey {
+			log.Info("用户通过场景关注", "user_id", userID, "scene", scene)
+			if action, ok := h.account.config().SceneActions[scene]; ok {
+				h.dispatchEventAction(ctx, c, msg, action)
+				return
+			}
+		}
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, h.welcomeMessage()))
+	case "unsubscribe":
+		h.clearUserState(ctx, userID)
+		c.String(http.StatusOK, "success")
+	case "CLICK":
+		action, ok := h.account.config().MenuClicks[msg.EventKey]
+		if !ok {
+			log.Warn("未配置的菜单 EventKey", "event_key", msg.EventKey)
+			c.String(http.StatusOK, "success")
+			return
+		}
+		h.dispatchEventAction(ctx, c, msg, action)
+	case "SCAN":
+		// 已关注用户扫描带参数二维码，EventKey 即为场景值
+		action, ok := h.account.config().SceneActions[msg.EventKey]
+		if !ok {
+			c.String(http.StatusOK, "success")
+			return
+		}
+		h.dispatchEventAction(ctx, c, msg, action)
+	case "VIEW":
+		// 跳转链接的菜单无需回复
+		c.String(http.StatusOK, "success")
+	default:
+		c.String(http.StatusOK, "success")
+	}
+}
+
+// welcomeMessage 返回关注时的欢迎语
+func (h *Handler) welcomeMessage() string {
+	if welcome := h.account.config().WelcomeMessage; welcome != "" {
+		return welcome
+	}
+	return defaultWelcomeMessage
+}
+
+// dispatchEventAction 将菜单或场景映射的动作当作用户发送的指令或问题处理
+func (h *Handler) dispatchEventAction(ctx context.Context, c *gin.Context, msg models.WeChatMessage, action string) {
+	// 事件消息没有 MsgId，使用与关联ID相同的 FromUserName + CreateTime 排重
+	msg.MsgId = messageRequestID(msg)
+	msg.MsgType = "text"
+	msg.Content = action
+
+	h.handleTextMessage(ctx, c, msg)
+}
+
+// clearUserState 清除用户的答案缓存、分段缓存和RAGFlow会话
+func (h *Handler) clearUserState(ctx context.Context, userID string) {
+	log := logging.FromContext(ctx)
+	if err := h.store.DeleteUserAnswers(userID); err != nil {
+		log.Error("清除答案记录失败", "error", err)
+	}
+
+	clearMoreParts(userID)
+	h.clearSessions(ctx, userID)
+	if err := h.store.DeleteUserKnowledgeBase(userID); err != nil {
+		log.Error("删除知识库选择失败", "error", err)
+	}
+	log.Info("已清理取消关注用户的会话和缓存", "user_id", userID)
+}
@@ -2,59 +2,179 @@ package wechat
 
 import (
 	"bytes"
-	"crypto/sha1"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
-	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
 	"github.com/johnqing-424/WeChat-RAG/internal/models"
+	"github.com/johnqing-424/WeChat-RAG/internal/moderation"
+	"github.com/johnqing-424/WeChat-RAG/internal/queue"
 	"github.com/johnqing-424/WeChat-RAG/internal/ragflow"
+	"github.com/johnqing-424/WeChat-RAG/internal/ratelimit"
+	"github.com/johnqing-424/WeChat-RAG/internal/redact"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
 )
 
-// 消息缓存
-var (
-	// 用户ID -> 答案数据 (用于用户会话)
-	userAnswerCache = make(map[string]*answerData)
-	// 消息ID -> 答案数据 (用于处理微信重试)
-	messageCacheMap = make(map[string]*answerData)
-	cacheLock       sync.RWMutex
-)
+// Handler 处理微信公众号的消息回调
+type Handler struct {
+	account   *Account
+	rag       ragflow.Service
+	store     store.Store
+	pool      *queue.Pool
+	limiter   *ratelimit.Limiter
+	moderator *moderation.Moderator
+	settings  atomic.Pointer[handlerSettings]
+
+	answerLock sync.Mutex     // 保证答案记录读取-修改-保存的原子性
+	background sync.WaitGroup // 被动回复之后仍在进行的答案保存和推送
+}
+
+// handlerSettings 是由RAGFlow配置决定的问答设置，配置热更新时整体替换
+type handlerSettings struct {
+	stream         bool             // 是否使用流式接口
+	answerMode     string           // 回答模式，见 ragflow.AnswerMode*
+	redactPII      bool             // 问题中的个人信息是否替换为占位符后再发送给RAGFlow
+	knowledgeBases []*knowledgeBase // 知识库路由表，第一个为默认知识库
+}
+
+// NewHandler 创建公众号的消息处理器，rag 为问答使用的RAGFlow服务，st 保存答案和消息排重记录，pool 限制并发的RAGFlow请求，
+// limiter 限制每个用户的提问频率和每日次数，moderator 审核问题和答案
+func NewHandler(account *Account, rag ragflow.Service, st store.Store, pool *queue.Pool, limiter *ratelimit.Limiter, moderator *moderation.Moderator, ragCfg config.RagFlowConfig) *Handler {
+	h := &Handler{
+		account:   account,
+		rag:       rag,
+		store:     st,
+		pool:      pool,
+		limiter:   limiter,
+		moderator: moderator,
+	}
+	h.updateSettings(ragCfg)
+	return h
+}
+
+// UpdateConfig 替换公众号配置和问答设置，答案记录、会话和分段缓存保留；RAGFlow客户端需单独更新
+func (h *Handler) UpdateConfig(acc config.AccountConfig) {
+	h.account.UpdateConfig(acc.WeChat)
+	h.updateSettings(acc.RagFlow)
+}
+
+func (h *Handler) updateSettings(ragCfg config.RagFlowConfig) {
+	h.settings.Store(&handlerSettings{
+		stream:         ragCfg.Stream,
+		answerMode:     ragflow.NormalizeAnswerMode(ragCfg.AnswerMode),
+		redactPII:      ragCfg.RedactPII,
+		knowledgeBases: loadKnowledgeBases(ragCfg),
+	})
+}
+
+// knowledgeBases 返回当前的知识库路由表
+func (h *Handler) knowledgeBases() []*knowledgeBase {
+	return h.settings.Load().knowledgeBases
+}
+
+// goBackground 在后台执行答案保存和推送，停止服务时通过 Wait 等待其完成
+func (h *Handler) goBackground(fn func()) {
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		fn()
+	}()
+}
+
+// Wait 等待后台的答案保存和推送完成，ctx 结束时不再等待并返回 ctx 的错误
+func (h *Handler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.background.Wait()
+		close(done)
+	}()
 
-// 答案缓存数据结构
-type answerData struct {
-	UserID        string    // 用户ID
-	LastQuestion  string    // 最后一个问题
-	Answer        string    // 准备好的答案
-	IsReady       bool      // 答案是否准备好
-	ProcessingMsg string    // 处理中的消息
-	CreatedAt     time.Time // 创建时间
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// saveNewAnswer 保存一条新消息的处理记录，记录所属的账号以便重启后继续处理
+func (h *Handler) saveNewAnswer(answer *store.Answer) {
+	h.answerLock.Lock()
+	defer h.answerLock.Unlock()
+
+	answer.Account = h.account.Name
+	if err := h.store.SaveAnswer(answer); err != nil {
+		slog.Error("保存答案记录失败", "request_id", answer.MsgID, "error", err)
+	}
+}
+
+// updateAnswer 读取并修改消息的答案记录，记录不存在时不做处理
+func (h *Handler) updateAnswer(msgID string, update func(answer *store.Answer)) {
+	h.answerLock.Lock()
+	defer h.answerLock.Unlock()
+
+	answer, ok, err := h.store.GetAnswer(msgID)
+	if err != nil {
+		slog.Error("读取答案记录失败", "request_id", msgID, "error", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	update(answer)
+	if err := h.store.SaveAnswer(answer); err != nil {
+		slog.Error("保存答案记录失败", "request_id", msgID, "error", err)
+	}
+}
+
+// storeAnswer 将答案写入消息的处理记录
+func (h *Handler) storeAnswer(msgID, answer string) {
+	h.updateAnswer(msgID, func(a *store.Answer) {
+		a.Answer = answer
+		a.IsReady = true
+	})
+}
+
+// lookupMessage 对消息ID排重，重复消息返回已有的处理记录
+func (h *Handler) lookupMessage(msgID string) (*store.Answer, bool) {
+	isNew, err := h.store.MarkMessage(msgID)
+	if err != nil {
+		slog.Error("记录消息ID失败", "request_id", msgID, "error", err)
+	}
+	if isNew || err != nil {
+		return nil, false
+	}
+
+	answer, ok, err := h.store.GetAnswer(msgID)
+	if err != nil || !ok {
+		// 重复消息但记录尚未保存，按处理中返回
+		return &store.Answer{MsgID: msgID, ProcessingMsg: h.processingMessage(0)}, true
+	}
+	return answer, true
 }
 
 // VerifyWeChatToken 是用于验证微信服务器的 Token 回调
-func VerifyWeChatToken(c *gin.Context) {
+func (h *Handler) VerifyWeChatToken(c *gin.Context) {
 	signature := c.Query("signature")
 	timestamp := c.Query("timestamp")
 	nonce := c.Query("nonce")
 	echostr := c.Query("echostr")
 
-	// 1. 将token、timestamp、nonce三个参数进行字典序排序
-	strs := []string{Token, timestamp, nonce}
-	sort.Strings(strs)
-
-	// 2. 将三个参数字符串拼接成一个字符串进行sha1加密
-	str := strings.Join(strs, "")
-	h := sha1.New()
-	h.Write([]byte(str))
-	sum := fmt.Sprintf("%x", h.Sum(nil))
-
-	// 3. 开发者获得加密后的字符串可与signature对比，标识该请求来源于微信
-	if sum == signature {
+	// 签名与 signature 一致说明请求来源于微信
+	if checkSignature(h.account.config().Token, signature, timestamp, nonce) {
 		c.String(http.StatusOK, echostr)
 	} else {
 		c.String(http.StatusOK, "验证失败")
@@ -81,155 +201,250 @@ func createWeChatXMLResponse(fromUser, toUser, content string) string {
 }
 
 // HandleWeChatMessage 处理用户发送的消息
-func HandleWeChatMessage(c *gin.Context) {
+func (h *Handler) HandleWeChatMessage(c *gin.Context) {
 	defer c.Request.Body.Close()
 	body, _ := ioutil.ReadAll(c.Request.Body)
 
+	// 安全模式/兼容模式下，校验msg_signature并解密消息体
+	if isEncryptedRequest(c) {
+		mc, err := h.account.getMsgCrypt()
+		if err != nil {
+			slog.Error("消息加解密配置错误", "account", h.account.Name, "error", err)
+			c.String(http.StatusOK, "success")
+			return
+		}
+
+		plainBody, err := mc.decryptRequest(body, c.Query("msg_signature"), c.Query("timestamp"), c.Query("nonce"))
+		if err != nil {
+			slog.Warn("消息解密失败", "account", h.account.Name, "error", err)
+			c.String(http.StatusForbidden, "签名校验失败")
+			return
+		}
+		body = plainBody
+	}
+
 	var msg models.WeChatMessage
 	err := xml.NewDecoder(bytes.NewReader(body)).Decode(&msg)
 	if err != nil {
-		fmt.Println("XML 解析失败:", err)
-		c.String(http.StatusOK, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, "消息解析失败"))
+		slog.Warn("XML 解析失败", "account", h.account.Name, "error", err)
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, "消息解析失败"))
 		return
 	}
 
+	metrics.MessagesReceived.Inc(h.account.Name, msg.MsgType)
+
+	// 以 MsgId 作为关联ID，之后的处理、RAGFlow请求和异步推送的日志都带有该ID
+	ctx := logging.NewContext(messageRequestID(msg))
+	logging.FromContext(ctx).Info("收到消息", "account", h.account.Name, "user_id", msg.FromUserName, "msg_type", msg.MsgType)
+
+	// 黑名单和提问限制在任何RAGFlow请求之前检查，微信重试的消息不重复计数
+	if msg.MsgType != "event" || msg.Event != "unsubscribe" {
+		_, retry, _ := h.store.GetAnswer(messageRequestID(msg))
+		if reply, ok := h.admitUser(ctx, msg.FromUserName, h.isQuestion(msg) && !retry); !ok {
+			if reply == "" {
+				c.String(http.StatusOK, "success")
+			} else {
+				h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, reply))
+			}
+			return
+		}
+	}
+
+	switch msg.MsgType {
+	case "event":
+		// 事件消息(关注、取消关注、菜单点击、扫码等)单独处理
+		h.handleEventMessage(ctx, c, msg)
+	case "voice":
+		h.handleVoiceMessage(ctx, c, msg)
+	default:
+		h.handleTextMessage(ctx, c, msg)
+	}
+}
+
+// messageRequestID 返回消息的关联ID；事件消息没有 MsgId，按微信建议使用 FromUserName + CreateTime
+func messageRequestID(msg models.WeChatMessage) string {
+	if msg.MsgId != "" {
+		return msg.MsgId
+	}
+	return fmt.Sprintf("%s_%d", msg.FromUserName, msg.CreateTime)
+}
+
+// handleTextMessage 处理用户的文本提问或指令
+func (h *Handler) handleTextMessage(ctx context.Context, c *gin.Context, msg models.WeChatMessage) {
+	log := logging.FromContext(ctx)
 	userID := msg.FromUserName
 	msgID := msg.MsgId // 消息ID用于重试识别
-	fmt.Printf("用户 [%s] 提问: %s, MsgId: %s\n", userID, msg.Content, msgID)
+	log.Info("用户提问", "user_id", userID, logging.Body("question", msg.Content))
 
 	// 检查是否是指令消息
 	if strings.HasPrefix(msg.Content, "/") {
-		handleCommandMessage(c, msg)
+		h.handleCommandMessage(ctx, c, msg)
 		return
 	}
 
-	// 检查缓存中是否有该消息ID的处理记录（用于处理微信重试）
-	cacheLock.RLock()
-	msgData, msgExists := messageCacheMap[msgID]
-	cacheLock.RUnlock()
+	// 检查是否有该消息ID的处理记录（用于处理微信重试）
+	msgData, msgExists := h.lookupMessage(msgID)
 
 	// 如果消息已处理过，直接使用处理结果
 	if msgExists {
+		if msgData.Pushed {
+			// 答案已通过客服消息推送，避免重复回复
+			c.String(http.StatusOK, "success")
+			return
+		}
 		if msgData.IsReady {
 			// 已有答案，直接返回
-			fmt.Println("返回已处理消息的答案:", msgData.Answer)
-			xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, msgData.Answer)
-			c.String(http.StatusOK, xmlResponse)
+			log.Info("重试消息返回已处理的答案", logging.Body("answer", msgData.Answer))
+			xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, h.repeatAnswerReply(userID, msgData.Answer))
+			h.writeWeChatReply(c, xmlResponse)
 			return
 		}
 		// 正在处理，返回处理中的消息
-		fmt.Println("返回处理中消息:", msgData.ProcessingMsg)
+		log.Info("重试消息返回处理中提示")
 		xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, msgData.ProcessingMsg)
-		c.String(http.StatusOK, xmlResponse)
+		h.writeWeChatReply(c, xmlResponse)
 		return
 	}
 
-	// 新消息，加入缓存
-	processingMsg := "您的问题正在处理中，可稍后发送 /status 查询结果。"
-	newMsgData := &answerData{
+	// 审核问题，命中时屏蔽敏感词后继续回答，或直接回复预设的消息、不回复
+	moderated := h.moderator.Check(ctx, moderation.StageQuestion, userID, msg.Content)
+	switch moderated.Action {
+	case moderation.ActionBlock:
+		c.String(http.StatusOK, "success")
+		return
+	case moderation.ActionReplace:
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, moderated.Text))
+		return
+	}
+	msg.Content = moderated.Text
+
+	// 新消息，加入缓存，同时作为用户最近一条消息，用于status查询
+	h.saveNewAnswer(&store.Answer{
+		MsgID:         msgID,
 		UserID:        userID,
-		LastQuestion:  msg.Content,
-		ProcessingMsg: processingMsg,
+		Question:      msg.Content,
+		ProcessingMsg: h.processingMessage(0),
 		CreatedAt:     time.Now(),
-	}
+	})
 
-	cacheLock.Lock()
-	messageCacheMap[msgID] = newMsgData
-	// 更新用户最近一条消息缓存，用于status查询
-	userAnswerCache[userID] = newMsgData
-	cacheLock.Unlock()
+	// 流式模式下，首段内容可以在超时前先行回复(仅聊天助手模式支持流式)
+	if settings := h.settings.Load(); settings.stream && settings.answerMode == ragflow.AnswerModeChat {
+		h.handleStreamingAnswer(ctx, c, msg)
+		return
+	}
 
-	// 创建超时响应通道
-	answerChan := make(chan string, 1)
 	timeoutChan := time.After(4 * time.Second) // 4秒超时，微信要求5秒内回复
 
-	// 异步获取答案
-	go func() {
-		// 获取完整答案
-		answer, err := getAnswerForQuestion(msg.Content, userID)
-		if err == nil && answer != "" {
-			answerChan <- answer
+	// 加入任务队列获取答案，同一用户的问题按顺序处理
+	answerChan, processingMsg, ok := h.submitAnswerJob(ctx, c, msg, func() string {
+		answer, err := h.getAnswerForQuestion(ctx, msgID, msg.Content, userID)
+		if err != nil {
+			answer = fmt.Sprintf("抱歉，获取答案失败: %v", err)
 		}
-	}()
+		return answer
+	})
+	if !ok {
+		return
+	}
 
 	// 等待答案或超时
 	select {
 	case answer := <-answerChan:
-		// 更新缓存
-		cacheLock.Lock()
-		if md, ok := messageCacheMap[msgID]; ok {
-			md.Answer = answer
-			md.IsReady = true
-			messageCacheMap[msgID] = md
-		}
-		cacheLock.Unlock()
+		// 更新答案记录
+		h.storeAnswer(msgID, answer)
 
 		// 直接返回答案
-		fmt.Println("直接返回答案:", answer)
-		xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, answer)
-		c.String(http.StatusOK, xmlResponse)
+		metrics.PassiveReplies.Inc(h.account.Name, "answered")
+		log.Info("直接返回答案", logging.Body("answer", answer))
+		xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, h.prepareAnswerReply(ctx, userID, answer))
+		h.writeWeChatReply(c, xmlResponse)
 	case <-timeoutChan:
 		// 超时，返回正在处理的消息
-		fmt.Println("超时，返回处理中消息")
+		metrics.PassiveReplies.Inc(h.account.Name, "timeout")
+		log.Info("超时，返回处理中消息")
 		xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, processingMsg)
-		c.String(http.StatusOK, xmlResponse)
-
-		// 继续异步处理问题，结果将存入缓存
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					fmt.Println("异步处理问题时发生错误:", r)
-					cacheLock.Lock()
-					if md, ok := messageCacheMap[msgID]; ok {
-						md.Answer = "抱歉，处理您的问题时发生了错误，请稍后再试。"
-						md.IsReady = true
-						messageCacheMap[msgID] = md
-					}
-					cacheLock.Unlock()
-				}
-			}()
-
-			// 获取完整答案
-			answer, err := getAnswerForQuestion(msg.Content, userID)
-			if err != nil {
-				answer = fmt.Sprintf("抱歉，获取答案失败: %v", err)
-			}
+		h.writeWeChatReply(c, xmlResponse)
+
+		// 等待队列中的任务完成，结果将存入缓存
+		h.goBackground(func() {
+			answer := <-answerChan
+
+			// 更新答案记录
+			h.storeAnswer(msgID, answer)
+			log.Info("已准备好回答(存入缓存)", logging.Body("answer", answer))
+
+			// 通过客服消息主动推送答案，失败时保留记录供 /status 查询
+			h.pushAnswer(ctx, msgID, userID, answer)
+		})
+	}
+}
+
+// submitAnswerJob 将获取答案的任务加入队列，返回结果通道和带排队位置的处理中消息；队列已满时直接回复系统繁忙
+func (h *Handler) submitAnswerJob(ctx context.Context, c *gin.Context, msg models.WeChatMessage, fn func() string) (<-chan string, string, bool) {
+	resultChan, ahead, err := h.pool.Submit(msg.FromUserName, fn)
+	if err != nil {
+		logging.FromContext(ctx).Warn("提交任务失败", "error", err)
+		h.storeAnswer(msg.MsgId, busyMessage)
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, busyMessage))
+		return nil, "", false
+	}
 
-			// 更新缓存
-			cacheLock.Lock()
-			if md, ok := messageCacheMap[msgID]; ok {
-				md.Answer = answer
-				md.IsReady = true
-				messageCacheMap[msgID] = md
+	processingMsg := h.processingMessage(ahead)
+	if ahead > 0 {
+		logging.FromContext(ctx).Info("问题进入排队", "ahead", ahead)
+		h.updateAnswer(msg.MsgId, func(a *store.Answer) {
+			if !a.IsReady {
+				a.ProcessingMsg = processingMsg
 			}
-			cacheLock.Unlock()
-			fmt.Println("已准备好回答(存入缓存):", answer)
-		}()
+		})
+	}
+	return resultChan, processingMsg, true
+}
+
+// 队列已满时的回复
+const busyMessage = "系统繁忙，请稍后再试。"
+
+// processingMessage 返回处理中的提示，ahead 为排在前面的问题数
+func (h *Handler) processingMessage(ahead int) string {
+	status := "您的问题正在处理中"
+	if ahead > 0 {
+		status = fmt.Sprintf("您的问题已进入排队，前面还有%d个问题", ahead)
+	}
+
+	if h.account.customMessageEnabled() {
+		return status + "，答案准备好后将自动发送给您，也可稍后发送 /status 查询结果。"
 	}
+	return status + "，可稍后发送 /status 查询结果。"
 }
 
-// 快速获取预设答案，用于首次尝试在微信超时前返回
-func getQuickAnswerForQuestion(question, userID string) (string, error) {
-	// 注释掉预设回答，强制使用RAGFlow
-	/*
-		if strings.Contains(question, "公司") {
-			return "浙江腾视科技有限公司是中国本土领先的生成式AI算力模组及边缘智算AGI普惠落地解决方案提供商。公司成立于2017年，核心团队来自华为、中兴等知名企业。", nil
-		} else if strings.Contains(question, "业务") {
-			return "公司主要业务包括提供机器人控制全栈AI边缘智算大脑、基于主流AI芯片的边缘算力模组、\"感知-决策-控制\"一体化边缘智算平台以及自主研发的AI加速引擎。", nil
-		} else if strings.Contains(question, "团队") {
-			return "公司核心团队成员来自华为、中兴等知名企业，创始人李泽湘担任技术总顾问，谢兮煜担任技术总顾问兼创始人/CEO。", nil
-		} else if strings.Contains(question, "产品") {
-			return "公司产品包括边缘算力模组和边缘计算终端，能够提供从1到500 Top的算力范围，形成了丰富的产品线。", nil
+// pushAnswer 通过客服消息接口推送已准备好的答案，超长答案分段推送
+func (h *Handler) pushAnswer(ctx context.Context, msgID, userID, answer string) {
+	log := logging.FromContext(ctx)
+	parts := splitAnswer(cleanAnswer(answer), h.maxReplyBytes())
+	err := h.account.SendCustomTextMessage(ctx, userID, parts[0])
+	if err != nil {
+		if errors.Is(err, ErrCustomMessageUnavailable) {
+			log.Info("客服消息不可用，回退到 /status 查询", "error", err)
+		} else {
+			log.Warn("推送答案失败，回退到 /status 查询", "error", err)
 		}
-	*/
+		return
+	}
+
+	h.updateAnswer(msgID, func(a *store.Answer) {
+		a.Pushed = true
+	})
+	log.Info("已通过客服消息推送答案", "user_id", userID)
 
-	// 不匹配任何预设关键词，返回空表示需要通过RagFlow获取答案
-	return "", fmt.Errorf("无预设答案")
+	if len(parts) > 1 {
+		h.pushMoreParts(ctx, userID, setMoreParts(userID, parts[1:]))
+	}
 }
 
 // 清理RAGFlow回答中的特殊标记
 func cleanAnswer(answer string) string {
-	// 只去除不必要的标记，保留原文引用标记##$$
+	// 去除不必要的标记，引用标记##n$$替换为[1]形式的脚注
+	answer = ragflow.ReplaceCitations(answer)
 	specialMarks := []string{"CITATIONS:", "CITATIONS: "}
 	for _, mark := range specialMarks {
 		answer = strings.Replace(answer, mark, "", -1)
@@ -241,52 +456,123 @@ func cleanAnswer(answer string) string {
 	return answer
 }
 
-// 获取问题的回答
-func getAnswerForQuestion(question, userID string) (string, error) {
-	fmt.Println("开始获取完整答案，问题:", question)
-
-	// 检查问题中是否包含关键词 - 现在完全注释掉这部分代码，强制使用RAGFlow
-	/*
-		if strings.Contains(question, "公司") {
-			return "浙江腾视科技有限公司是中国本土领先的生成式AI算力模组及边缘智算AGI普惠落地解决方案提供商。公司成立于2017年，核心团队来自华为、中兴等知名企业。", nil
-		} else if strings.Contains(question, "业务") {
-			return "公司主要业务包括提供机器人控制全栈AI边缘智算大脑、基于主流AI芯片的边缘算力模组、\"感知-决策-控制\"一体化边缘智算平台以及自主研发的AI加速引擎。", nil
-		} else if strings.Contains(question, "团队") {
-			return "公司核心团队成员来自华为、中兴等知名企业，创始人李泽湘担任技术总顾问，谢兮煜担任技术总顾问兼创始人/CEO。", nil
-		} else if strings.Contains(question, "产品") {
-			return "公司产品包括边缘算力模组和边缘计算终端，能够提供从1到500 Top的算力范围，形成了丰富的产品线。", nil
-		}
-	*/
-
-	// 获取知识库答案
-	chunks, err := ragflow.RetrieveChunks(question)
+// getAnswerForQuestion 获取问题的回答并审核，命中敏感词时按配置屏蔽或替换
+func (h *Handler) getAnswerForQuestion(ctx context.Context, msgID, question, userID string) (string, error) {
+	question, restore := h.redactQuestion(ctx, question)
+	answer, err := h.queryAnswer(ctx, msgID, question, userID)
 	if err != nil {
-		fmt.Println("检索知识块失败:", err)
-	} else {
-		fmt.Printf("检索到 %d 个知识块\n", len(chunks))
-		for i, chunk := range chunks {
-			if i < 2 { // 只打印前两个，避免日志过长
-				if len(chunk.Content) > 0 {
-					fmt.Printf("知识块 %d: %s\n", i+1, chunk.Content[:min(50, len(chunk.Content))])
-				}
-			}
-		}
+		return answer, err
+	}
+	return h.moderateAnswer(ctx, userID, restore(answer)), nil
+}
+
+// redactQuestion 开启 redact_pii 时把问题中的手机号、身份证号等替换为占位符，返回的函数把答案中的占位符还原为原文
+func (h *Handler) redactQuestion(ctx context.Context, question string) (string, func(string) string) {
+	if !h.settings.Load().redactPII {
+		return question, func(answer string) string { return answer }
+	}
+
+	var placeholders redact.Placeholders
+	redacted := placeholders.Replace(question)
+	if placeholders.Len() > 0 {
+		logging.FromContext(ctx).Info("问题中的个人信息已替换为占位符", "count", placeholders.Len())
+	}
+	return redacted, placeholders.Restore
+}
+
+// moderateAnswer 审核答案，返回屏蔽或替换后的答案
+func (h *Handler) moderateAnswer(ctx context.Context, userID, answer string) string {
+	return h.moderator.Check(ctx, moderation.StageAnswer, userID, answer).Text
+}
+
+// queryAnswer 按回答模式向RAGFlow查询问题的回答
+func (h *Handler) queryAnswer(ctx context.Context, msgID, question, userID string) (string, error) {
+	log := logging.FromContext(ctx)
+	log.Info("开始获取完整答案")
+
+	kb := h.routeKnowledgeBase(ctx, userID, question)
+	switch h.settings.Load().answerMode {
+	case ragflow.AnswerModeRetrieveLLM:
+		return h.getRetrievalAnswer(ctx, msgID, question, kb, true), nil
+	case ragflow.AnswerModeRetrieveOnly:
+		return h.getRetrievalAnswer(ctx, msgID, question, kb, false), nil
 	}
 
 	// 调用 RAGFlow API 获取答案
-	fmt.Println("开始调用 RAGFlow API")
-	answer, err := ragflow.QueryRagFlow(question, userID)
+	log.Info("开始调用 RAGFlow API", "knowledge_base", kb.name)
+	answer, reference, err := h.ragFor(kb).Complete(ctx, question, userID)
 	if err != nil {
-		fmt.Println("RAGFlow查询失败:", err)
+		log.Error("RAGFlow查询失败", "error", err)
 		return fmt.Sprintf("抱歉，系统暂时无法回答您的问题\"%s\"，请稍后再试或者尝试其他问题。错误: %v", question, err), nil
 	}
 
-	// 清理RAGFlow返回的答案
-	cleanedAnswer := cleanAnswer(answer)
-	fmt.Println("RAGFlow返回答案:", cleanedAnswer)
+	// 清理RAGFlow返回的答案并附上参考资料
+	cleanedAnswer := h.withSources(msgID, answer, reference)
+	log.Info("RAGFlow返回答案", logging.Body("answer", cleanedAnswer))
 	return cleanedAnswer, nil
 }
 
+// getRetrievalAnswer 检索知识库后回答问题，useLLM 为 true 时由模型根据检索内容作答，模型不可用时退回检索摘录
+func (h *Handler) getRetrievalAnswer(ctx context.Context, msgID, question string, kb *knowledgeBase, useLLM bool) string {
+	log := logging.FromContext(ctx)
+	rag := h.ragFor(kb)
+	chunks, err := rag.Retrieve(ctx, question, kb.retrievalOverride())
+	if err != nil {
+		log.Error("检索知识块失败", "error", err)
+		return fmt.Sprintf("抱歉，系统暂时无法回答您的问题\"%s\"，请稍后再试或者尝试其他问题。错误: %v", question, err)
+	}
+
+	log.Info("检索到知识块", "knowledge_base", kb.name, "count", len(chunks))
+	for i, chunk := range chunks {
+		if i < 2 { // 只打印前两个，避免日志过长
+			log.Debug("知识块", "index", i+1, "document", chunk.DocumentName, "similarity", chunk.Similarity, logging.Body("content", chunk.Content[:min(50, len(chunk.Content))]))
+		}
+	}
+
+	reference := ragflow.ReferenceFromChunks(chunks)
+	if !useLLM || len(chunks) == 0 {
+		// 摘录中已标注来源，只保存引用列表
+		h.saveSources(msgID, reference)
+		return ragflow.FormatExcerpt(chunks)
+	}
+
+	log.Info("开始调用模型根据检索内容作答")
+	answer, err := rag.QueryLLMFreeAnswer(ctx, ragflow.BuildPrompt(question, chunks))
+	if err != nil {
+		log.Warn("模型查询失败，返回检索摘录", "error", err)
+		h.saveSources(msgID, reference)
+		return ragflow.FormatExcerpt(chunks)
+	}
+
+	cleanedAnswer := h.withSources(msgID, answer, reference)
+	log.Info("模型返回答案", logging.Body("answer", cleanedAnswer))
+	return cleanedAnswer
+}
+
+// withSources 清理答案中的引用标记并在末尾附上参考资料，同时保存完整引用列表供 /sources 查看
+func (h *Handler) withSources(msgID, answer string, reference *ragflow.Reference) string {
+	cited := ragflow.CitedChunks(answer)
+	cleanedAnswer := cleanAnswer(answer)
+	h.saveSources(msgID, reference)
+
+	if sources := ragflow.FormatSources(cited, reference); sources != "" {
+		cleanedAnswer += "\n\n" + sources
+	}
+	return cleanedAnswer
+}
+
+// saveSources 保存答案的完整引用列表
+func (h *Handler) saveSources(msgID string, reference *ragflow.Reference) {
+	sources := ragflow.FormatReferenceList(reference)
+	if sources == "" {
+		return
+	}
+
+	h.updateAnswer(msgID, func(a *store.Answer) {
+		a.Sources = sources
+	})
+}
+
 // min函数帮助截断日志输出
 func min(a, b int) int {
 	if a < b {
@@ -296,44 +582,85 @@ func min(a, b int) int {
 }
 
 // 处理指令消息
-func handleCommandMessage(c *gin.Context, msg models.WeChatMessage) {
+func (h *Handler) handleCommandMessage(ctx context.Context, c *gin.Context, msg models.WeChatMessage) {
+	// 直接使用自定义XML格式
+	xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, h.commandReply(ctx, msg))
+	h.writeWeChatReply(c, xmlResponse)
+}
+
+// commandReply 执行指令并返回回复内容
+func (h *Handler) commandReply(ctx context.Context, msg models.WeChatMessage) string {
+	log := logging.FromContext(ctx)
 	var content string
 
 	switch msg.Content {
 	case "/help":
-		content = "欢迎使用RAG智能问答系统！\n\n您可以直接发送问题与系统对话，系统会尝试从知识库中寻找答案或使用AI回答。\n\n可用命令：\n/help - 显示帮助信息\n/清空 - 清空会话历史\n/重置 - 重置会话\n/status - 查询上一个问题的处理状态"
+		content = "欢迎使用RAG智能问答系统！\n\n您可以直接发送问题与系统对话，系统会尝试从知识库中寻找答案或使用AI回答。\n\n可用命令：\n/help - 显示帮助信息\n/清空 - 清空会话历史\n/重置 - 重置会话\n/status - 查询上一个问题的处理状态\n/more - 查看较长答案的后续内容\n/sources - 查看上一个回答的参考资料\n/kb - 查看和切换知识库"
 	case "/清空":
-		// 清除该用户的缓存
-		cacheLock.Lock()
-		delete(userAnswerCache, msg.FromUserName)
-		cacheLock.Unlock()
+		// 清除该用户的答案记录
+		if err := h.store.DeleteUserAnswers(msg.FromUserName); err != nil {
+			log.Error("清除答案记录失败", "error", err)
+		}
+		clearMoreParts(msg.FromUserName)
 		content = "您的会话历史已清空，开始新的对话。"
 	case "/重置":
-		// 清除该用户的缓存和会话
-		cacheLock.Lock()
-		delete(userAnswerCache, msg.FromUserName)
-		cacheLock.Unlock()
-		ragflow.ClearSessionCache(msg.FromUserName)
+		// 清除该用户的答案记录和会话
+		if err := h.store.DeleteUserAnswers(msg.FromUserName); err != nil {
+			log.Error("清除答案记录失败", "error", err)
+		}
+		clearMoreParts(msg.FromUserName)
+		h.clearSessions(ctx, msg.FromUserName)
 		content = "系统已重置，开始新的对话。"
 	case "/status":
 		// 检查用户最近一条消息的处理状态
-		cacheLock.RLock()
-		userData, exists := userAnswerCache[msg.FromUserName]
-		cacheLock.RUnlock()
+		userData, exists, err := h.store.GetUserAnswer(msg.FromUserName)
+		if err != nil {
+			log.Error("读取答案记录失败", "error", err)
+		}
 
 		if !exists {
 			content = "没有找到您的历史消息记录。"
 		} else if userData.IsReady {
 			// 确保返回的答案也经过清理
-			content = "您的上一个问题已处理完成，答案是：\n\n" + cleanAnswer(userData.Answer)
+			content = "您的上一个问题已处理完成，答案是：\n\n" + h.repeatAnswerReply(msg.FromUserName, userData.Answer)
 		} else {
-			content = "您的问题 \"" + userData.LastQuestion + "\" 仍在处理中，请稍候再查询。"
+			content = "您的问题 \"" + userData.Question + "\" 仍在处理中，请稍候再查询。"
 		}
+	case "/more":
+		// 返回较长答案的下一段
+		part, remaining, ok := nextMorePart(msg.FromUserName)
+		if !ok {
+			content = "没有更多内容了。"
+		} else if remaining > 0 {
+			content = fmt.Sprintf("%s\n\n（还有%d段，回复 /more 继续查看）", part, remaining)
+		} else {
+			content = part
+		}
+	case "/sources":
+		// 返回最近一个回答的完整引用列表
+		userData, exists, err := h.store.GetUserAnswer(msg.FromUserName)
+		if err != nil {
+			log.Error("读取答案记录失败", "error", err)
+		}
+
+		if !exists {
+			content = "没有找到您的历史消息记录。"
+		} else if !userData.IsReady {
+			content = "您的问题 \"" + userData.Question + "\" 仍在处理中，请稍候再查询。"
+		} else if userData.Sources == "" {
+			content = "上一个回答没有引用知识库中的资料。"
+		} else {
+			content = "上一个回答的参考资料：\n\n" + userData.Sources
+		}
+	case "/kb":
+		content = h.handleKnowledgeBaseCommand(msg.FromUserName, "")
 	default:
-		content = "未识别的指令，您可以直接发送问题来获取回答。可用指令：/help、/清空、/重置、/status"
+		if arg, ok := strings.CutPrefix(msg.Content, "/kb "); ok {
+			content = h.handleKnowledgeBaseCommand(msg.FromUserName, strings.TrimSpace(arg))
+			break
+		}
+		content = "未识别的指令，您可以直接发送问题来获取回答。可用指令：/help、/清空、/重置、/status、/more、/sources、/kb"
 	}
 
-	// 直接使用自定义XML格式
-	xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, content)
-	c.String(http.StatusOK, xmlResponse)
+	return content
 }
@@ -0,0 +1,209 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/ragflow"
+)
+
+// knowledgeBase 是一个可路由的知识库
+type knowledgeBase struct {
+	name        string
+	description string
+	chatID      string   // 为空时使用默认聊天助手
+	datasetIDs  []string // 为空时使用默认检索配置
+	retrieval   config.RetrievalOverrideConfig
+	keywords    []string
+	patterns    []*regexp.Regexp
+}
+
+// loadKnowledgeBases 根据配置生成知识库路由表，第一个为默认知识库
+func loadKnowledgeBases(cfg config.RagFlowConfig) []*knowledgeBase {
+	kbs := []*knowledgeBase{{
+		name:        cfg.DefaultKnowledgeBaseName(),
+		description: "未命中其他知识库时使用",
+	}}
+
+	for _, kbCfg := range cfg.KnowledgeBases {
+		kb := &knowledgeBase{
+			name:        kbCfg.Name,
+			description: kbCfg.Description,
+			chatID:      kbCfg.ChatID,
+			datasetIDs:  kbCfg.DatasetIDs,
+			retrieval:   kbCfg.Retrieval,
+			keywords:    kbCfg.Keywords,
+		}
+		for _, pattern := range kbCfg.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				slog.Warn("知识库的正则无效，已忽略", "knowledge_base", kbCfg.Name, "pattern", pattern, "error", err)
+				continue
+			}
+			kb.patterns = append(kb.patterns, re)
+		}
+		kbs = append(kbs, kb)
+	}
+
+	return kbs
+}
+
+// matches 判断问题是否命中知识库的关键词或正则
+func (kb *knowledgeBase) matches(question string) bool {
+	for _, keyword := range kb.keywords {
+		if keyword != "" && strings.Contains(question, keyword) {
+			return true
+		}
+	}
+	for _, re := range kb.patterns {
+		if re.MatchString(question) {
+			return true
+		}
+	}
+	return false
+}
+
+// retrievalOverride 返回该知识库的知识库ID和检索参数覆盖，未配置的项使用默认检索配置
+func (kb *knowledgeBase) retrievalOverride() *ragflow.RetrievalOverride {
+	return &ragflow.RetrievalOverride{DatasetIDs: kb.datasetIDs, RetrievalOverrideConfig: kb.retrieval}
+}
+
+// findKnowledgeBase 按名称或序号(从1开始)查找知识库
+func (h *Handler) findKnowledgeBase(nameOrIndex string) *knowledgeBase {
+	kbs := h.knowledgeBases()
+	if n, err := strconv.Atoi(nameOrIndex); err == nil && n >= 1 && n <= len(kbs) {
+		return kbs[n-1]
+	}
+	for _, kb := range kbs {
+		if kb.name == nameOrIndex {
+			return kb
+		}
+	}
+	return nil
+}
+
+// selectedKnowledgeBase 返回用户通过 /kb 选择的知识库，未选择时返回 nil
+func (h *Handler) selectedKnowledgeBase(userID string) *knowledgeBase {
+	name, ok, err := h.store.GetUserKnowledgeBase(userID)
+	if err != nil {
+		slog.Error("读取知识库选择失败", "user_id", userID, "error", err)
+	}
+	if !ok {
+		return nil
+	}
+	return h.findKnowledgeBase(name)
+}
+
+// routeKnowledgeBase 为问题选择知识库：优先使用用户的选择，其次按关键词和正则匹配，最后使用默认知识库
+func (h *Handler) routeKnowledgeBase(ctx context.Context, userID, question string) *knowledgeBase {
+	if kb := h.selectedKnowledgeBase(userID); kb != nil {
+		return kb
+	}
+
+	kbs := h.knowledgeBases()
+	for _, kb := range kbs[1:] {
+		if kb.matches(question) {
+			logging.FromContext(ctx).Info("问题命中知识库的路由规则", "knowledge_base", kb.name)
+			return kb
+		}
+	}
+	return kbs[0]
+}
+
+// ragFor 返回知识库对应的RAGFlow服务
+func (h *Handler) ragFor(kb *knowledgeBase) ragflow.Service {
+	return h.rag.ForChat(kb.chatID)
+}
+
+// clearSessions 清除用户在所有知识库聊天助手下的会话
+func (h *Handler) clearSessions(ctx context.Context, userID string) {
+	cleared := make(map[string]bool)
+	for _, kb := range h.knowledgeBases() {
+		if cleared[kb.chatID] {
+			continue
+		}
+		cleared[kb.chatID] = true
+		h.ragFor(kb).ClearSession(ctx, userID)
+	}
+}
+
+// SessionInfo 是用户在一个知识库聊天助手下的RAGFlow会话
+type SessionInfo struct {
+	Account       string `json:"account"`
+	KnowledgeBase string `json:"knowledge_base"`
+	SessionID     string `json:"session_id"`
+}
+
+// UserSessions 列出用户在各知识库聊天助手下缓存的会话
+func (h *Handler) UserSessions(userID string) []SessionInfo {
+	var sessions []SessionInfo
+	seen := make(map[string]bool)
+	for _, kb := range h.knowledgeBases() {
+		if seen[kb.chatID] {
+			continue
+		}
+		seen[kb.chatID] = true
+		if sessionID, ok := h.ragFor(kb).SessionID(userID); ok {
+			sessions = append(sessions, SessionInfo{Account: h.account.Name, KnowledgeBase: kb.name, SessionID: sessionID})
+		}
+	}
+	return sessions
+}
+
+// ClearUserSessions 强制清除用户的RAGFlow会话和分段缓存，答案记录保留
+func (h *Handler) ClearUserSessions(ctx context.Context, userID string) {
+	clearMoreParts(userID)
+	h.clearSessions(ctx, userID)
+}
+
+// handleKnowledgeBaseCommand 处理 /kb 指令：不带参数时列出知识库，带名称或序号时切换，auto 恢复自动选择
+func (h *Handler) handleKnowledgeBaseCommand(userID, arg string) string {
+	switch arg {
+	case "":
+		return h.knowledgeBaseList(userID)
+	case "auto":
+		if err := h.store.DeleteUserKnowledgeBase(userID); err != nil {
+			slog.Error("删除知识库选择失败", "user_id", userID, "error", err)
+		}
+		return "已恢复根据问题自动选择知识库。"
+	}
+
+	kb := h.findKnowledgeBase(arg)
+	if kb == nil {
+		return fmt.Sprintf("没有找到知识库\"%s\"，发送 /kb 查看可用的知识库。", arg)
+	}
+	if err := h.store.SaveUserKnowledgeBase(userID, kb.name); err != nil {
+		slog.Error("保存知识库选择失败", "user_id", userID, "error", err)
+		return "切换知识库失败，请稍后再试。"
+	}
+	return fmt.Sprintf("已切换到知识库\"%s\"，之后的问题都将在该知识库中查找。发送 /kb auto 恢复自动选择。", kb.name)
+}
+
+// knowledgeBaseList 返回知识库列表，标出用户当前选择的知识库
+func (h *Handler) knowledgeBaseList(userID string) string {
+	selected := h.selectedKnowledgeBase(userID)
+
+	var b strings.Builder
+	b.WriteString("可用的知识库：")
+	for i, kb := range h.knowledgeBases() {
+		b.WriteString(fmt.Sprintf("\n%d. %s", i+1, kb.name))
+		if kb.description != "" {
+			b.WriteString(" - " + kb.description)
+		}
+		if kb == selected {
+			b.WriteString(" (当前)")
+		}
+	}
+
+	if selected == nil {
+		b.WriteString("\n\n当前根据问题自动选择知识库。")
+	}
+	b.WriteString("\n发送 /kb 名称或序号 切换知识库，/kb auto 恢复自动选择。")
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+	"github.com/johnqing-424/WeChat-RAG/internal/models"
+	"github.com/johnqing-424/WeChat-RAG/internal/ratelimit"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+)
+
+// admitUser 检查黑白名单和提问限制，question 为 true 时消耗一次提问次数；
+// 不处理该消息时返回 false 和回复给用户的消息(为空时不回复)
+func (h *Handler) admitUser(ctx context.Context, userID string, question bool) (string, bool) {
+	log := logging.FromContext(ctx)
+
+	access, _, err := h.store.GetUserAccess(userID)
+	if err != nil {
+		log.Error("查询黑白名单失败", "error", err)
+	}
+	switch access {
+	case store.AccessBlock:
+		metrics.RateLimited.Inc(h.account.Name, "blocked")
+		log.Info("黑名单用户的消息不处理", "user_id", userID)
+		return h.limiter.BlockedMessage(), false
+	case store.AccessAllow:
+		return "", true
+	}
+
+	if !question || h.limiter == nil {
+		return "", true
+	}
+	result := h.limiter.Allow(userID)
+	if result.Allowed {
+		return "", true
+	}
+
+	metrics.RateLimited.Inc(h.account.Name, result.Reason)
+	log.Info("用户提问超出限制", "user_id", userID, "reason", result.Reason, "retry_at", result.RetryAt.Format(time.DateTime))
+	return limitMessage(result, time.Now()), false
+}
+
+// isQuestion 判断消息是否会作为问题调用RAGFlow，指令和普通事件不计入提问次数
+func (h *Handler) isQuestion(msg models.WeChatMessage) bool {
+	switch msg.MsgType {
+	case "event":
+		var action string
+		switch msg.Event {
+		case "CLICK":
+			action = h.account.config().MenuClicks[msg.EventKey]
+		case "SCAN":
+			action = h.account.config().SceneActions[msg.EventKey]
+		}
+		return action != "" && !strings.HasPrefix(action, "/")
+	case "voice":
+		return true
+	default:
+		return !strings.HasPrefix(msg.Content, "/")
+	}
+}
+
+// limitMessage 返回超出限制时回复用户的消息，说明何时可以再次提问
+func limitMessage(result ratelimit.Result, now time.Time) string {
+	if result.Reason == ratelimit.ReasonDaily {
+		return fmt.Sprintf("您今天的提问次数已达上限(%d次)，请明天再来提问。", result.Limit)
+	}
+
+	wait := result.RetryAt.Sub(now)
+	if wait < time.Minute {
+		return fmt.Sprintf("您提问太频繁了，请%d秒后再试。", int(math.Ceil(wait.Seconds())))
+	}
+	return fmt.Sprintf("您提问太频繁了，请%d分钟后再试。", int(math.Ceil(wait.Minutes())))
+}
@@ -0,0 +1,245 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 自定义菜单接口地址
+const (
+	menuCreateURL = "https://api.weixin.qq.com/cgi-bin/menu/create?access_token=%s"
+	menuGetURL    = "https://api.weixin.qq.com/cgi-bin/get_current_selfmenu_info?access_token=%s"
+	menuDeleteURL = "https://api.weixin.qq.com/cgi-bin/menu/delete?access_token=%s"
+
+	menuRequestTimeout = 10 * time.Second
+)
+
+// 微信自定义菜单的限制
+const (
+	maxTopLevelButtons   = 3
+	maxSubButtons        = 5
+	maxTopLevelNameWidth = 8   // 一级菜单最多4个汉字或8个字母，按 menuNameWidth 计算
+	maxSubNameWidth      = 40  // 二级菜单最多20个汉字或40个字母(实际显示约8个汉字)
+	maxKeyBytes          = 128 // 菜单KEY值
+	maxURLBytes          = 1024
+)
+
+// Menu 是自定义菜单定义
+type Menu struct {
+	Buttons []MenuButton `yaml:"button" json:"button"`
+}
+
+// MenuButton 是菜单按钮，包含子菜单时无需设置类型
+type MenuButton struct {
+	Type       string       `yaml:"type,omitempty" json:"type,omitempty"`
+	Name       string       `yaml:"name" json:"name"`
+	Key        string       `yaml:"key,omitempty" json:"key,omitempty"`
+	URL        string       `yaml:"url,omitempty" json:"url,omitempty"`
+	AppID      string       `yaml:"appid,omitempty" json:"appid,omitempty"`
+	PagePath   string       `yaml:"pagepath,omitempty" json:"pagepath,omitempty"`
+	MediaID    string       `yaml:"media_id,omitempty" json:"media_id,omitempty"`
+	ArticleID  string       `yaml:"article_id,omitempty" json:"article_id,omitempty"`
+	SubButtons []MenuButton `yaml:"sub_button,omitempty" json:"sub_button,omitempty"`
+}
+
+// LoadMenu 从YAML文件读取菜单定义
+func LoadMenu(path string) (*Menu, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取菜单文件失败: %w", err)
+	}
+
+	var menu Menu
+	if err := yaml.Unmarshal(data, &menu); err != nil {
+		return nil, fmt.Errorf("解析菜单文件失败: %w", err)
+	}
+	return &menu, nil
+}
+
+// Validate 按微信的限制校验菜单定义
+func (m *Menu) Validate() error {
+	if len(m.Buttons) == 0 || len(m.Buttons) > maxTopLevelButtons {
+		return fmt.Errorf("一级菜单数量应为1~%d个，当前为%d个", maxTopLevelButtons, len(m.Buttons))
+	}
+
+	for i, button := range m.Buttons {
+		path := fmt.Sprintf("button[%d]", i)
+		if err := validateButtonName(path, button.Name, maxTopLevelNameWidth); err != nil {
+			return err
+		}
+
+		if len(button.SubButtons) == 0 {
+			if err := validateButtonAction(path, button); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(button.SubButtons) > maxSubButtons {
+			return fmt.Errorf("%s (%s) 的二级菜单最多%d个，当前为%d个", path, button.Name, maxSubButtons, len(button.SubButtons))
+		}
+		for j, sub := range button.SubButtons {
+			subPath := fmt.Sprintf("%s.sub_button[%d]", path, j)
+			if len(sub.SubButtons) > 0 {
+				return fmt.Errorf("%s (%s) 不能再包含子菜单", subPath, sub.Name)
+			}
+			if err := validateButtonName(subPath, sub.Name, maxSubNameWidth); err != nil {
+				return err
+			}
+			if err := validateButtonAction(subPath, sub); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateButtonName 校验菜单名称长度
+func validateButtonName(path, name string, maxWidth int) error {
+	if name == "" {
+		return fmt.Errorf("%s 缺少名称", path)
+	}
+	if width := menuNameWidth(name); width > maxWidth {
+		return fmt.Errorf("%s (%s) 名称长度为%d，超过%d(汉字按2计算)", path, name, width, maxWidth)
+	}
+	return nil
+}
+
+// menuNameWidth 按微信的计算方式返回菜单名称长度：ASCII字符计1，汉字等其他字符计2，
+// 不能使用UTF-8字节数(汉字为3字节)，否则超长的名称会通过校验后被接口以40018拒绝
+func menuNameWidth(name string) int {
+	width := 0
+	for _, r := range name {
+		if r < utf8.RuneSelf {
+			width++
+		} else {
+			width += 2
+		}
+	}
+	return width
+}
+
+// validateButtonAction 根据按钮类型校验必填字段
+func validateButtonAction(path string, button MenuButton) error {
+	switch button.Type {
+	case "click", "scancode_push", "scancode_waitmsg", "pic_sysphoto", "pic_photo_or_album", "pic_weixin", "location_select":
+		if button.Key == "" {
+			return fmt.Errorf("%s (%s) 类型为%s，缺少key", path, button.Name, button.Type)
+		}
+		if len(button.Key) > maxKeyBytes {
+			return fmt.Errorf("%s (%s) 的key超过%d字节", path, button.Name, maxKeyBytes)
+		}
+	case "view":
+		if button.URL == "" {
+			return fmt.Errorf("%s (%s) 类型为view，缺少url", path, button.Name)
+		}
+	case "miniprogram":
+		if button.URL == "" || button.AppID == "" || button.PagePath == "" {
+			return fmt.Errorf("%s (%s) 类型为miniprogram，需要url、appid和pagepath", path, button.Name)
+		}
+	case "media_id", "view_limited":
+		if button.MediaID == "" {
+			return fmt.Errorf("%s (%s) 类型为%s，缺少media_id", path, button.Name, button.Type)
+		}
+	case "article_id", "article_view_limited":
+		if button.ArticleID == "" {
+			return fmt.Errorf("%s (%s) 类型为%s，缺少article_id", path, button.Name, button.Type)
+		}
+	case "":
+		return fmt.Errorf("%s (%s) 缺少type，或应包含sub_button", path, button.Name)
+	default:
+		return fmt.Errorf("%s (%s) 不支持的菜单类型: %s", path, button.Name, button.Type)
+	}
+
+	if len(button.URL) > maxURLBytes {
+		return fmt.Errorf("%s (%s) 的url超过%d字节", path, button.Name, maxURLBytes)
+	}
+	return nil
+}
+
+// JSON 返回提交给微信的菜单JSON，不转义URL中的&等字符
+func (m *Menu) JSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CreateMenu 创建自定义菜单，会覆盖公众号现有菜单
+func (a *Account) CreateMenu(m *Menu) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	body, err := m.JSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = a.callMenuAPI("POST", menuCreateURL, body)
+	return err
+}
+
+// GetMenu 查询公众号当前的自定义菜单，返回微信的原始JSON
+func (a *Account) GetMenu() ([]byte, error) {
+	return a.callMenuAPI("GET", menuGetURL, nil)
+}
+
+// DeleteMenu 删除公众号的自定义菜单
+func (a *Account) DeleteMenu() error {
+	_, err := a.callMenuAPI("GET", menuDeleteURL, nil)
+	return err
+}
+
+// callMenuAPI 调用菜单相关接口并检查错误码
+func (a *Account) callMenuAPI(method, urlFormat string, body []byte) ([]byte, error) {
+	token, err := a.GetAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("获取 access_token 失败: %w", err)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf(urlFormat, token), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: menuRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析菜单接口响应失败: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return nil, fmt.Errorf("菜单接口调用失败: %d - %s", result.ErrCode, result.ErrMsg)
+	}
+
+	return respBody, nil
+}
@@ -0,0 +1,74 @@
+package wechat
+
+import (
+	"fmt"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+)
+
+// 上次停止服务时未完成、且未重新处理的问题的答案
+const interruptedMessage = "服务重启，您的问题未能处理完成，请重新发送。"
+
+// ResumeAnswer 重新处理上次停止服务时未完成的问题，答案准备好后通过客服消息推送，推送失败时可通过 /status 查询
+func (h *Handler) ResumeAnswer(answer *store.Answer) error {
+	ctx := logging.NewContext(answer.MsgID)
+	msgID, userID, question := answer.MsgID, answer.UserID, answer.Question
+
+	answer.IsReady = false
+	answer.ProcessingMsg = h.processingMessage(0)
+	h.saveNewAnswer(answer)
+
+	_, _, err := h.pool.Submit(userID, func() string {
+		result, err := h.getAnswerForQuestion(ctx, msgID, question, userID)
+		if err != nil {
+			result = fmt.Sprintf("抱歉，获取答案失败: %v", err)
+		}
+
+		h.storeAnswer(msgID, result)
+		h.pushAnswer(ctx, msgID, userID, result)
+		return result
+	})
+	if err != nil {
+		h.storeAnswer(msgID, interruptedMessage)
+		return err
+	}
+	logging.FromContext(ctx).Info("重新处理上次未完成的问题", "user_id", userID)
+	return nil
+}
+
+// InterruptAnswer 把上次停止服务时未完成的问题标记为需要重新发送，并尽量通过客服消息告知用户
+func (h *Handler) InterruptAnswer(answer *store.Answer) {
+	ctx := logging.NewContext(answer.MsgID)
+
+	answer.Answer = interruptedMessage
+	answer.IsReady = true
+	h.saveNewAnswer(answer)
+	h.pushAnswer(ctx, answer.MsgID, answer.UserID, interruptedMessage)
+}
+
+// ResumeAnswer 重新处理上次停止服务时未完成的问题，答案通过应用消息发送
+func (wh *WeComHandler) ResumeAnswer(answer *store.Answer) error {
+	ctx := logging.NewContext(answer.MsgID)
+
+	answer.IsReady = false
+	wh.h.saveNewAnswer(answer)
+
+	if _, err := wh.submit(ctx, answer.MsgID, answer.UserID, answer.Question); err != nil {
+		wh.h.storeAnswer(answer.MsgID, interruptedMessage)
+		return err
+	}
+	logging.FromContext(ctx).Info("重新处理上次未完成的问题", "user_id", answer.UserID)
+	return nil
+}
+
+// InterruptAnswer 告知企业微信用户上次未完成的问题需要重新发送
+func (wh *WeComHandler) InterruptAnswer(answer *store.Answer) {
+	ctx := logging.NewContext(answer.MsgID)
+
+	answer.Answer = interruptedMessage
+	answer.IsReady = true
+	answer.Pushed = true
+	wh.h.saveNewAnswer(answer)
+	wh.reply(ctx, answer.UserID, interruptedMessage)
+}
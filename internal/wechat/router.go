@@ -0,0 +1,119 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router 将微信回调分发给对应公众号的处理器
+type Router struct {
+	handlers     []*Handler
+	byName       map[string]*Handler
+	byOriginalID map[string]*Handler
+}
+
+// NewRouter 创建多公众号路由，第一个处理器为无法识别账号时的默认处理器
+func NewRouter(handlers ...*Handler) *Router {
+	rt := &Router{
+		handlers:     handlers,
+		byName:       make(map[string]*Handler),
+		byOriginalID: make(map[string]*Handler),
+	}
+	for _, h := range handlers {
+		rt.byName[h.account.Name] = h
+		if h.account.OriginalID != "" {
+			rt.byOriginalID[h.account.OriginalID] = h
+		}
+	}
+	return rt
+}
+
+// Register 注册 /wechat 和 /wechat/:account 回调路由
+func (rt *Router) Register(r gin.IRoutes) {
+	// 微信 Token 验证（GET 请求）
+	r.GET("/wechat", rt.VerifyWeChatToken)
+	r.GET("/wechat/:account", rt.VerifyWeChatToken)
+
+	// 接收用户消息（POST 请求）
+	r.POST("/wechat", rt.HandleWeChatMessage)
+	r.POST("/wechat/:account", rt.HandleWeChatMessage)
+}
+
+// VerifyWeChatToken 验证服务器地址，未指定账号时依次尝试各账号的 Token
+func (rt *Router) VerifyWeChatToken(c *gin.Context) {
+	if name := c.Param("account"); name != "" {
+		h, ok := rt.byName[name]
+		if !ok {
+			c.String(http.StatusNotFound, "未知的公众号账号")
+			return
+		}
+		h.VerifyWeChatToken(c)
+		return
+	}
+
+	for _, h := range rt.handlers {
+		if checkSignature(h.account.config().Token, c.Query("signature"), c.Query("timestamp"), c.Query("nonce")) {
+			h.VerifyWeChatToken(c)
+			return
+		}
+	}
+	c.String(http.StatusOK, "验证失败")
+}
+
+// HandleWeChatMessage 按路径中的账号名称或消息的 ToUserName 找到处理器
+func (rt *Router) HandleWeChatMessage(c *gin.Context) {
+	if name := c.Param("account"); name != "" {
+		h, ok := rt.byName[name]
+		if !ok {
+			c.String(http.StatusNotFound, "未知的公众号账号")
+			return
+		}
+		h.HandleWeChatMessage(c)
+		return
+	}
+
+	h := rt.handlers[0]
+	if len(rt.handlers) > 1 {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			slog.Warn("读取请求体失败", "error", err)
+			c.String(http.StatusBadRequest, "无法读取请求体")
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// 明文和加密消息的外层都带有 ToUserName(公众号原始ID)
+		var envelope struct {
+			ToUserName string `xml:"ToUserName"`
+		}
+		if err := xml.Unmarshal(body, &envelope); err == nil {
+			if matched, ok := rt.byOriginalID[envelope.ToUserName]; ok {
+				h = matched
+			} else {
+				slog.Warn("未找到对应原始ID的公众号，使用默认账号", "original_id", envelope.ToUserName, "account", h.account.Name)
+			}
+		}
+	}
+
+	h.HandleWeChatMessage(c)
+}
+
+// checkSignature 校验 token、timestamp、nonce 的sha1签名
+func checkSignature(token, signature, timestamp, nonce string) bool {
+	strs := []string{token, timestamp, nonce}
+	sort.Strings(strs)
+
+	hash := sha1.New()
+	hash.Write([]byte(strings.Join(strs, "")))
+	return subtle.ConstantTimeCompare([]byte(fmt.Sprintf("%x", hash.Sum(nil))), []byte(signature)) == 1
+}
@@ -0,0 +1,221 @@
+package wechat
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+)
+
+const (
+	// 默认单条文本回复的最大字节数，微信上限约为2048字节，预留一定余量
+	defaultMaxReplyBytes = 2000
+	// 为“回复 /more”等提示语和指令前缀预留的字节数
+	replyHintReserveBytes = 200
+	// 被动回复发出后再推送后续分段，尽量保证用户看到的顺序正确
+	followUpPushDelay = 1 * time.Second
+)
+
+// 句子结束符，优先在这些字符之后切分
+const sentenceEnders = "。！？!?；;…"
+
+// 用户ID -> 尚未发送的答案分段 (用于 /more 指令)
+var (
+	moreCache     = make(map[string]*moreParts)
+	moreCacheLock sync.Mutex
+)
+
+// 某个答案剩余未发送的分段
+type moreParts struct {
+	parts []string
+}
+
+// maxReplyBytes 返回单条回复允许的最大字节数，未配置或不大于提示语预留字节数时使用默认值
+func (h *Handler) maxReplyBytes() int {
+	if maxBytes := h.account.config().MaxReplyBytes; maxBytes > replyHintReserveBytes {
+		return maxBytes
+	}
+	return defaultMaxReplyBytes
+}
+
+// splitAnswer 按段落、句子边界将答案切分为不超过 maxBytes 字节的多段，不会截断UTF-8字符
+func splitAnswer(text string, maxBytes int) []string {
+	text = strings.TrimSpace(text)
+	if maxBytes < 1 {
+		maxBytes = 1
+	}
+	if len(text) <= maxBytes {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+
+	for _, para := range strings.SplitAfter(text, "\n") {
+		pieces := []string{para}
+		if len(para) > maxBytes {
+			pieces = splitSentences(para)
+		}
+
+		for _, piece := range pieces {
+			// 单个句子仍然过长时，只能按字符边界硬切
+			for len(piece) > maxBytes {
+				flush()
+				cut := utf8CutIndex(piece, maxBytes)
+				parts = append(parts, piece[:cut])
+				piece = piece[cut:]
+			}
+
+			if current.Len()+len(piece) > maxBytes {
+				flush()
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// splitSentences 在句子结束符之后切分，保留结束符
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if strings.ContainsRune(sentenceEnders, r) {
+			end := i + utf8.RuneLen(r)
+			sentences = append(sentences, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// utf8CutIndex 返回不超过 maxBytes 且位于字符边界上的切分位置，至少为1个字符
+func utf8CutIndex(text string, maxBytes int) int {
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		// maxBytes 小于单个字符长度时，至少保留一个字符
+		_, size := utf8.DecodeRuneInString(text)
+		cut = size
+	}
+	return cut
+}
+
+// prepareAnswerReply 返回可直接被动回复的第一段答案，其余分段通过客服消息推送或留给 /more 查询
+func (h *Handler) prepareAnswerReply(ctx context.Context, userID, answer string) string {
+	parts := splitAnswer(cleanAnswer(answer), h.maxReplyBytes()-replyHintReserveBytes)
+
+	if len(parts) <= 1 {
+		clearMoreParts(userID)
+		return answer
+	}
+
+	rest := setMoreParts(userID, parts[1:])
+	if h.account.customMessageEnabled() {
+		h.goBackground(func() {
+			time.Sleep(followUpPushDelay)
+			h.pushMoreParts(ctx, userID, rest)
+		})
+		return parts[0] + "\n\n（内容较长，后续内容将陆续发送，也可回复 /more 查看）"
+	}
+
+	return parts[0] + "\n\n（内容较长，回复 /more 查看后续内容）"
+}
+
+// repeatAnswerReply 返回再次回复已送达过的答案(微信重试、/status)时的第一段，
+// 后续分段只留给 /more 查询，不再重复推送
+func (h *Handler) repeatAnswerReply(userID, answer string) string {
+	parts := splitAnswer(cleanAnswer(answer), h.maxReplyBytes()-replyHintReserveBytes)
+
+	if len(parts) <= 1 {
+		clearMoreParts(userID)
+		return answer
+	}
+
+	setMoreParts(userID, parts[1:])
+	return parts[0] + "\n\n（内容较长，回复 /more 查看后续内容）"
+}
+
+// pushMoreParts 依次通过客服消息推送剩余分段，失败时保留剩余分段供 /more 查询
+func (h *Handler) pushMoreParts(ctx context.Context, userID string, rest *moreParts) {
+	for {
+		moreCacheLock.Lock()
+		if len(rest.parts) == 0 {
+			moreCacheLock.Unlock()
+			return
+		}
+		part := rest.parts[0]
+		moreCacheLock.Unlock()
+
+		if err := h.account.SendCustomTextMessage(ctx, userID, part); err != nil {
+			logging.FromContext(ctx).Warn("推送后续分段失败，用户可通过 /more 查看", "error", err)
+			return
+		}
+
+		moreCacheLock.Lock()
+		if len(rest.parts) > 0 && rest.parts[0] == part {
+			rest.parts = rest.parts[1:]
+		}
+		moreCacheLock.Unlock()
+	}
+}
+
+// setMoreParts 保存用户尚未发送的答案分段，覆盖之前的记录
+func setMoreParts(userID string, parts []string) *moreParts {
+	rest := &moreParts{parts: parts}
+
+	moreCacheLock.Lock()
+	moreCache[userID] = rest
+	moreCacheLock.Unlock()
+
+	return rest
+}
+
+// clearMoreParts 清除用户尚未发送的答案分段
+func clearMoreParts(userID string) {
+	moreCacheLock.Lock()
+	delete(moreCache, userID)
+	moreCacheLock.Unlock()
+}
+
+// MoreCacheSize 返回保存了未发送分段的用户数
+func MoreCacheSize() int {
+	moreCacheLock.Lock()
+	defer moreCacheLock.Unlock()
+	return len(moreCache)
+}
+
+// nextMorePart 取出用户的下一段答案，返回剩余段数
+func nextMorePart(userID string) (string, int, bool) {
+	moreCacheLock.Lock()
+	defer moreCacheLock.Unlock()
+
+	rest, ok := moreCache[userID]
+	if !ok || len(rest.parts) == 0 {
+		return "", 0, false
+	}
+
+	part := rest.parts[0]
+	rest.parts = rest.parts[1:]
+	if len(rest.parts) == 0 {
+		delete(moreCache, userID)
+	}
+	return part, len(rest.parts), true
+}
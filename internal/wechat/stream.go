@@ -0,0 +1,147 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+	"github.com/johnqing-424/WeChat-RAG/internal/models"
+)
+
+// 先行回复的首段内容至少需要的字节数，避免只回复一个标题
+const minEarlyReplyBytes = 30
+
+// handleStreamingAnswer 通过流式接口获取答案，首段完整段落在超时前到达时先行回复，其余内容稍后送达
+func (h *Handler) handleStreamingAnswer(ctx context.Context, c *gin.Context, msg models.WeChatMessage) {
+	log := logging.FromContext(ctx)
+	userID := msg.FromUserName
+	msgID := msg.MsgId
+
+	prefixChan := make(chan string, 1)
+	timeoutChan := time.After(4 * time.Second) // 4秒超时，微信要求5秒内回复
+
+	answerChan, processingMsg, ok := h.submitAnswerJob(ctx, c, msg, func() string {
+		prefixFound := false
+		answer, err := h.getStreamingAnswerForQuestion(ctx, msgID, msg.Content, userID, func(partial string) {
+			if prefixFound {
+				return
+			}
+			if prefix := h.earlyReplyPrefix(partial); prefix != "" {
+				prefixFound = true
+				// 首段未通过审核时不先行回复，由完整答案的审核处理
+				if !h.moderator.Passes(ctx, userID, prefix) {
+					return
+				}
+				select {
+				case prefixChan <- prefix:
+				default:
+				}
+			}
+		})
+		if err != nil {
+			answer = fmt.Sprintf("抱歉，获取答案失败: %v", err)
+		}
+		return answer
+	})
+	if !ok {
+		return
+	}
+
+	select {
+	case answer := <-answerChan:
+		h.storeAnswer(msgID, answer)
+		metrics.PassiveReplies.Inc(h.account.Name, "answered")
+		log.Info("直接返回答案", logging.Body("answer", answer))
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, h.prepareAnswerReply(ctx, userID, answer)))
+	case prefix := <-prefixChan:
+		metrics.PassiveReplies.Inc(h.account.Name, "partial")
+		log.Info("先行返回首段内容", logging.Body("prefix", prefix))
+		hint := "\n\n（后续内容生成中，可稍后回复 /more 查看）"
+		if h.account.customMessageEnabled() {
+			hint = "\n\n（后续内容生成后将自动发送，也可稍后回复 /more 查看）"
+		}
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, prefix+hint))
+
+		h.goBackground(func() {
+			answer := <-answerChan
+			h.storeAnswer(msgID, answer)
+			h.deliverRemainder(ctx, userID, prefix, cleanAnswer(answer))
+		})
+	case <-timeoutChan:
+		metrics.PassiveReplies.Inc(h.account.Name, "timeout")
+		log.Info("超时，返回处理中消息")
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, processingMsg))
+
+		h.goBackground(func() {
+			answer := <-answerChan
+			h.storeAnswer(msgID, answer)
+			log.Info("已准备好回答(存入缓存)", logging.Body("answer", answer))
+			h.pushAnswer(ctx, msgID, userID, answer)
+		})
+	}
+}
+
+// getStreamingAnswerForQuestion 通过流式接口获取问题的回答
+func (h *Handler) getStreamingAnswerForQuestion(ctx context.Context, msgID, question, userID string, onPartial func(string)) (string, error) {
+	log := logging.FromContext(ctx)
+	log.Info("开始流式获取答案")
+
+	question, restore := h.redactQuestion(ctx, question)
+	kb := h.routeKnowledgeBase(ctx, userID, question)
+	answer, reference, err := h.ragFor(kb).CompleteStream(ctx, question, userID, func(partial string) {
+		onPartial(restore(partial))
+	})
+	if err != nil {
+		log.Error("RAGFlow流式查询失败", "error", err)
+		// 超时但已收到部分内容时返回已有内容
+		if !errors.Is(err, context.DeadlineExceeded) || answer == "" {
+			return restore(fmt.Sprintf("抱歉，系统暂时无法回答您的问题\"%s\"，请稍后再试或者尝试其他问题。错误: %v", question, err)), nil
+		}
+	}
+
+	cleanedAnswer := restore(h.withSources(msgID, answer, reference))
+	log.Info("RAGFlow返回答案", logging.Body("answer", cleanedAnswer))
+	return h.moderateAnswer(ctx, userID, cleanedAnswer), nil
+}
+
+// earlyReplyPrefix 返回可以先行回复的开头若干完整段落，首段过短(如标题)时继续累积后续段落，
+// 尚未形成足够长的完整段落时返回空
+func (h *Handler) earlyReplyPrefix(partial string) string {
+	cleaned := cleanAnswer(partial)
+	end := 0
+	for {
+		idx := strings.Index(cleaned[end:], "\n\n")
+		if idx < 0 {
+			return ""
+		}
+		end += idx
+		if len(strings.TrimSpace(cleaned[:end])) >= minEarlyReplyBytes {
+			break
+		}
+		end += len("\n\n")
+	}
+
+	return splitAnswer(cleaned[:end], h.maxReplyBytes()-replyHintReserveBytes)[0]
+}
+
+// deliverRemainder 发送已先行回复的首段之后的剩余内容
+func (h *Handler) deliverRemainder(ctx context.Context, userID, prefix, answer string) {
+	remainder := strings.TrimSpace(answer)
+	if strings.HasPrefix(remainder, prefix) {
+		remainder = strings.TrimSpace(strings.TrimPrefix(remainder, prefix))
+	}
+	if remainder == "" {
+		clearMoreParts(userID)
+		return
+	}
+
+	rest := setMoreParts(userID, splitAnswer(remainder, h.maxReplyBytes()))
+	if h.account.customMessageEnabled() {
+		h.pushMoreParts(ctx, userID, rest)
+	}
+}
@@ -1,6 +1,7 @@
 package wechat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,39 +10,182 @@ import (
 	"time"
 
 	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
 )
 
-// 从配置文件加载微信配置
-var (
-	cfg       = config.GetConfig()
-	TokenURL  = cfg.WeChat.TokenURL
-	AppID     = cfg.WeChat.AppID
-	AppSecret = cfg.WeChat.AppSecret
-	Token     = cfg.WeChat.Token
-)
+// Account 是一个公众号的配置及其 access_token 缓存
+type Account struct {
+	Name       string // 账号名称，对应回调地址 /wechat/<name>
+	OriginalID string // 公众号原始ID，用于按 ToUserName 路由
+
+	mu    sync.RWMutex
+	cfg   config.WeChatConfig
+	crypt *msgCrypt // 首次使用时创建，配置更新后重建
+
+	tokens tokenCache
+}
+
+// NewAccount 根据公众号配置创建账号
+func NewAccount(acc config.AccountConfig) *Account {
+	return &Account{
+		Name:       acc.Name,
+		OriginalID: acc.OriginalID,
+		cfg:        acc.WeChat,
+		tokens:     tokenCache{app: acc.Name},
+	}
+}
+
+// config 返回账号当前的配置
+func (a *Account) config() config.WeChatConfig {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cfg
+}
+
+// UpdateConfig 替换公众号配置，AppID 或 AppSecret 变化时丢弃缓存的 access_token
+func (a *Account) UpdateConfig(cfg config.WeChatConfig) {
+	a.mu.Lock()
+	old := a.cfg
+	a.cfg = cfg
+	a.crypt = nil
+	a.mu.Unlock()
+
+	if old.AppID != cfg.AppID || old.AppSecret != cfg.AppSecret || old.TokenURL != cfg.TokenURL {
+		a.tokens.reset()
+	}
+}
+
+// GetAccessToken 获取当前有效的 access_token，如果过期则自动刷新
+func (a *Account) GetAccessToken() (string, error) {
+	return a.tokens.get(a.tokenURL())
+}
+
+func (a *Account) tokenURL() string {
+	cfg := a.config()
+	return fmt.Sprintf(cfg.TokenURL, cfg.AppID, cfg.AppSecret)
+}
+
+// 企业微信获取 access_token 的默认接口
+const defaultWeComTokenURL = "https://qyapi.weixin.qq.com/cgi-bin/gettoken?corpid=%s&corpsecret=%s"
+
+// 获取 access_token 的请求超时
+const tokenRequestTimeout = 10 * time.Second
+
+// WeComApp 是一个企业微信自建应用的配置及其 access_token 缓存
+type WeComApp struct {
+	mu    sync.RWMutex
+	cfg   config.WeComConfig
+	crypt *msgCrypt // 首次使用时创建，配置更新后重建
+
+	tokens tokenCache
+}
+
+// NewWeComApp 根据企业微信配置创建自建应用
+func NewWeComApp(cfg config.WeComConfig) *WeComApp {
+	return &WeComApp{cfg: cfg, tokens: tokenCache{app: "wecom"}}
+}
+
+// config 返回自建应用当前的配置
+func (w *WeComApp) config() config.WeComConfig {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cfg
+}
+
+// UpdateConfig 替换企业微信配置，企业ID或Secret变化时丢弃缓存的 access_token
+func (w *WeComApp) UpdateConfig(cfg config.WeComConfig) {
+	w.mu.Lock()
+	old := w.cfg
+	w.cfg = cfg
+	w.crypt = nil
+	w.mu.Unlock()
+
+	if old.CorpID != cfg.CorpID || old.CorpSecret != cfg.CorpSecret || old.TokenURL != cfg.TokenURL {
+		w.tokens.reset()
+	}
+}
+
+// GetAccessToken 获取企业微信应用当前有效的 access_token，如果过期则自动刷新
+func (w *WeComApp) GetAccessToken() (string, error) {
+	return w.tokens.get(w.tokenURL())
+}
 
-var (
+func (w *WeComApp) tokenURL() string {
+	cfg := w.config()
+	tokenURL := cfg.TokenURL
+	if tokenURL == "" {
+		tokenURL = defaultWeComTokenURL
+	}
+	return fmt.Sprintf(tokenURL, cfg.CorpID, cfg.CorpSecret)
+}
+
+// tokenCache 缓存 access_token，公众号和企业微信的获取接口返回格式相同
+type tokenCache struct {
+	app         string // 监控指标中的应用名称
+	mutex       sync.RWMutex
 	accessToken string
 	expireTime  time.Time
-	mutex       sync.RWMutex
-)
+}
 
-// GetAccessToken 获取当前有效的 access_token，如果过期则自动刷新
-func GetAccessToken() (string, error) {
-	mutex.RLock()
-	if time.Now().Before(expireTime) {
-		defer mutex.RUnlock()
-		return accessToken, nil
+// get 返回缓存的 access_token，过期时通过 url 刷新
+func (t *tokenCache) get(url string) (string, error) {
+	t.mutex.RLock()
+	if time.Now().Before(t.expireTime) {
+		defer t.mutex.RUnlock()
+		return t.accessToken, nil
 	}
-	mutex.RUnlock()
+	t.mutex.RUnlock()
+
+	return t.refresh(url)
+}
+
+// refresh 通过 url 获取新的 access_token，并记录刷新结果
+func (t *tokenCache) refresh(url string) (string, error) {
+	token, err := t.fetch(url)
+	if err != nil {
+		metrics.TokenRefreshes.Inc(t.app, "failure")
+		return "", err
+	}
+	metrics.TokenRefreshes.Inc(t.app, "success")
+	return token, nil
+}
+
+// post 使用缓存的 access_token 调用 send 发送请求，返回的错误码表示 access_token 失效时强制刷新后重试一次，
+// 返回最后一次请求的错误码和错误信息
+func (t *tokenCache) post(ctx context.Context, url string, send func(token string) (int, string, error)) (int, string, error) {
+	for attempt := 0; ; attempt++ {
+		var token string
+		var err error
+		if attempt == 0 {
+			token, err = t.get(url)
+		} else {
+			token, err = t.refresh(url)
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("获取 access_token 失败: %w", err)
+		}
+
+		errCode, errMsg, err := send(token)
+		if err != nil || attempt > 0 || (errCode != errCodeInvalidToken && errCode != errCodeTokenExpired) {
+			return errCode, errMsg, err
+		}
+		logging.FromContext(ctx).Warn("access_token 已失效，刷新后重试", "app", t.app, "errcode", errCode)
+	}
+}
 
-	return refreshToken()
+// reset 丢弃缓存的 access_token，下次使用时重新获取
+func (t *tokenCache) reset() {
+	t.mutex.Lock()
+	t.accessToken = ""
+	t.expireTime = time.Time{}
+	t.mutex.Unlock()
 }
 
-// refreshToken 刷新 access_token
-func refreshToken() (string, error) {
-	url := fmt.Sprintf(TokenURL, AppID, AppSecret)
-	resp, err := http.Get(url)
+// fetch 请求获取 access_token 的接口并更新缓存
+func (t *tokenCache) fetch(url string) (string, error) {
+	client := &http.Client{Timeout: tokenRequestTimeout}
+	resp, err := client.Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -65,10 +209,10 @@ func refreshToken() (string, error) {
 		return "", fmt.Errorf("获取 access_token 失败: %d - %s", result.ErrCode, result.ErrMsg)
 	}
 
-	mutex.Lock()
-	accessToken = result.AccessToken
-	expireTime = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second)
-	mutex.Unlock()
+	t.mutex.Lock()
+	t.accessToken = result.AccessToken
+	t.expireTime = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second)
+	t.mutex.Unlock()
 
-	return accessToken, nil
+	return result.AccessToken, nil
 }
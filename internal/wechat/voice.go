@@ -0,0 +1,229 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/models"
+	"github.com/johnqing-424/WeChat-RAG/internal/moderation"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+)
+
+const (
+	defaultMediaGetURL  = "https://api.weixin.qq.com/cgi-bin/media/get?access_token=%s&media_id=%s"
+	mediaRequestTimeout = 30 * time.Second
+	asrRequestTimeout   = 60 * time.Second
+)
+
+// ASRFunc 将语音数据识别为文本，format 为微信语音格式(如 amr、speex)
+type ASRFunc func(audio []byte, format string) (string, error)
+
+// asr 返回当前账号使用的语音识别函数，没有可用的识别服务时返回nil
+func (h *Handler) asr() ASRFunc {
+	if asrURL := h.account.config().ASRURL; asrURL != "" {
+		return httpASR(asrURL)
+	}
+	return nil
+}
+
+// handleVoiceMessage 处理语音消息，优先使用微信的语音识别结果
+func (h *Handler) handleVoiceMessage(ctx context.Context, c *gin.Context, msg models.WeChatMessage) {
+	recognition := strings.TrimSpace(msg.Recognition)
+	if recognition != "" {
+		logging.FromContext(ctx).Info("使用微信语音识别结果", logging.Body("recognition", recognition))
+		msg.Content = recognition
+		h.handleTextMessage(ctx, c, msg)
+		return
+	}
+
+	if h.asr() == nil {
+		xmlResponse := createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, "抱歉，未能识别您的语音，请尝试发送文字提问。")
+		h.writeWeChatReply(c, xmlResponse)
+		return
+	}
+
+	// 微信重试时直接返回已有的处理结果
+	msgData, msgExists := h.lookupMessage(msg.MsgId)
+	if msgExists {
+		if msgData.Pushed {
+			c.String(http.StatusOK, "success")
+			return
+		}
+		reply := msgData.ProcessingMsg
+		if msgData.IsReady {
+			reply = h.repeatAnswerReply(msg.FromUserName, msgData.Answer)
+		}
+		h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, reply))
+		return
+	}
+
+	// 下载和识别语音耗时较长，异步处理后通过客服消息推送或 /status 查询
+	processingMsg := "正在识别您的语音，可稍后发送 /status 查询结果。"
+	if h.account.customMessageEnabled() {
+		processingMsg = "正在识别您的语音，答案准备好后将自动发送给您，也可稍后发送 /status 查询结果。"
+	}
+	h.saveNewAnswer(&store.Answer{
+		MsgID:         msg.MsgId,
+		UserID:        msg.FromUserName,
+		Question:      "[语音]",
+		ProcessingMsg: processingMsg,
+		CreatedAt:     time.Now(),
+	})
+
+	_, _, err := h.pool.Submit(msg.FromUserName, func() string {
+		h.processVoiceMessage(ctx, msg)
+		return ""
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("提交任务失败", "error", err)
+		h.storeAnswer(msg.MsgId, busyMessage)
+		processingMsg = busyMessage
+	}
+
+	h.writeWeChatReply(c, createWeChatXMLResponse(msg.ToUserName, msg.FromUserName, processingMsg))
+}
+
+// processVoiceMessage 下载语音素材并识别，再走正常的问答流程
+func (h *Handler) processVoiceMessage(ctx context.Context, msg models.WeChatMessage) {
+	log := logging.FromContext(ctx)
+	userID := msg.FromUserName
+	msgID := msg.MsgId
+
+	var answer string
+	question, err := h.recognizeVoice(msg.MediaId, msg.Format)
+	if err != nil {
+		log.Warn("语音识别失败", "error", err)
+		answer = "抱歉，未能识别您的语音，请尝试发送文字提问。"
+	} else {
+		log.Info("本地语音识别完成", logging.Body("recognition", question))
+
+		// 已回复过处理中的提示，问题未通过审核时统一回复预设的消息
+		moderated := h.moderator.Check(ctx, moderation.StageQuestion, userID, question)
+		if moderated.Action == moderation.ActionPass || moderated.Action == moderation.ActionMask {
+			question = moderated.Text
+			answer, err = h.getAnswerForQuestion(ctx, msgID, question, userID)
+			if err != nil {
+				answer = fmt.Sprintf("抱歉，获取答案失败: %v", err)
+			}
+		} else {
+			question = ""
+			answer = h.moderator.ReplaceMessage()
+		}
+	}
+
+	h.updateAnswer(msgID, func(a *store.Answer) {
+		if question != "" {
+			a.Question = question
+		}
+		a.Answer = answer
+		a.IsReady = true
+	})
+
+	h.pushAnswer(ctx, msgID, userID, answer)
+}
+
+// recognizeVoice 通过临时素材接口下载语音并交给识别钩子
+func (h *Handler) recognizeVoice(mediaID, format string) (string, error) {
+	audio, err := h.account.downloadMedia(mediaID)
+	if err != nil {
+		return "", fmt.Errorf("下载语音素材失败: %w", err)
+	}
+
+	text, err := h.asr()(audio, strings.ToLower(format))
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("识别结果为空")
+	}
+	return text, nil
+}
+
+// downloadMedia 通过临时素材接口下载多媒体文件
+func (a *Account) downloadMedia(mediaID string) ([]byte, error) {
+	token, err := a.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaURL := a.config().MediaGetURL
+	if mediaURL == "" {
+		mediaURL = defaultMediaGetURL
+	}
+
+	client := &http.Client{Timeout: mediaRequestTimeout}
+	resp, err := client.Get(fmt.Sprintf(mediaURL, token, mediaID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 出错时微信返回JSON格式的错误信息
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") || strings.Contains(resp.Header.Get("Content-Type"), "text/plain") {
+		var result struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := json.Unmarshal(body, &result); err == nil && result.ErrCode != 0 {
+			return nil, fmt.Errorf("%d - %s", result.ErrCode, result.ErrMsg)
+		}
+	}
+
+	return body, nil
+}
+
+// httpASR 返回调用本地语音识别服务的识别函数，服务返回 {"text": "..."}
+func httpASR(asrURL string) ASRFunc {
+	return func(audio []byte, format string) (string, error) {
+		return postASR(asrURL, audio, format)
+	}
+}
+
+// postASR 将语音数据发送到本地的语音识别服务
+func postASR(asrURL string, audio []byte, format string) (string, error) {
+	req, err := http.NewRequest("POST", asrURL, bytes.NewReader(audio))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "audio/"+format)
+	req.Header.Set("X-Audio-Format", format)
+
+	client := &http.Client{Timeout: asrRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("语音识别服务返回状态码 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析语音识别结果失败: %w", err)
+	}
+	return result.Text, nil
+}
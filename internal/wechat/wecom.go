@@ -0,0 +1,286 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnqing-424/WeChat-RAG/internal/config"
+	"github.com/johnqing-424/WeChat-RAG/internal/logging"
+	"github.com/johnqing-424/WeChat-RAG/internal/metrics"
+	"github.com/johnqing-424/WeChat-RAG/internal/models"
+	"github.com/johnqing-424/WeChat-RAG/internal/moderation"
+	"github.com/johnqing-424/WeChat-RAG/internal/queue"
+	"github.com/johnqing-424/WeChat-RAG/internal/ragflow"
+	"github.com/johnqing-424/WeChat-RAG/internal/ratelimit"
+	"github.com/johnqing-424/WeChat-RAG/internal/store"
+)
+
+const (
+	defaultWeComSendURL     = "https://qyapi.weixin.qq.com/cgi-bin/message/send?access_token=%s"
+	defaultWeComReplyBytes  = 2000 // 企业微信文本消息最长2048字节
+	wecomSendRequestTimeout = 10 * time.Second
+)
+
+// WeComHandler 处理企业微信自建应用的消息回调，答案通过应用消息接口发送
+type WeComHandler struct {
+	app *WeComApp
+	h   *Handler // 复用公众号的问答流程、指令和答案记录
+}
+
+// NewWeComHandler 创建企业微信消息处理器，问答流程与公众号相同
+func NewWeComHandler(app *WeComApp, rag ragflow.Service, st store.Store, pool *queue.Pool, limiter *ratelimit.Limiter, moderator *moderation.Moderator, ragCfg config.RagFlowConfig) *WeComHandler {
+	// 企业微信没有被动回复的时限问题，答案统一通过应用消息发送，较长答案的后续分段通过 /more 查看
+	account := &Account{Name: "wecom", cfg: wecomAccountConfig(app.config())}
+	return &WeComHandler{
+		app: app,
+		h:   NewHandler(account, rag, st, pool, limiter, moderator, ragCfg),
+	}
+}
+
+// UpdateConfig 替换企业微信应用和问答流程使用的配置，RAGFlow客户端需单独更新
+func (wh *WeComHandler) UpdateConfig(cfg config.WeComConfig, ragCfg config.RagFlowConfig) {
+	wh.app.UpdateConfig(cfg)
+	wh.h.account.UpdateConfig(wecomAccountConfig(cfg))
+	wh.h.updateSettings(ragCfg)
+}
+
+// wecomAccountConfig 返回企业微信问答流程使用的账号配置，只需要单条消息的长度限制
+func wecomAccountConfig(cfg config.WeComConfig) config.WeChatConfig {
+	maxBytes := cfg.MaxReplyBytes
+	if maxBytes <= 0 || maxBytes > defaultWeComReplyBytes {
+		maxBytes = defaultWeComReplyBytes
+	}
+	return config.WeChatConfig{MaxReplyBytes: maxBytes}
+}
+
+// Handler 返回企业微信共用的问答处理器
+func (wh *WeComHandler) Handler() *Handler {
+	return wh.h
+}
+
+// getMsgCrypt 返回企业微信的消息加解密器，解密后校验的 ReceiveId 为企业ID
+func (w *WeComApp) getMsgCrypt() (*msgCrypt, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.crypt == nil {
+		mc, err := newMsgCrypt(w.cfg.Token, w.cfg.CorpID, w.cfg.EncodingAESKey)
+		if err != nil {
+			return nil, err
+		}
+		w.crypt = mc
+	}
+	return w.crypt, nil
+}
+
+// VerifyURL 是企业微信验证回调地址的接口，校验签名并解密 echostr 后原样返回
+func (wh *WeComHandler) VerifyURL(c *gin.Context) {
+	mc, err := wh.app.getMsgCrypt()
+	if err != nil {
+		slog.Error("企业微信消息加解密配置错误", "error", err)
+		c.String(http.StatusInternalServerError, "配置错误")
+		return
+	}
+
+	echostr := c.Query("echostr")
+	if !mc.verify(c.Query("msg_signature"), c.Query("timestamp"), c.Query("nonce"), echostr) {
+		c.String(http.StatusForbidden, "签名校验失败")
+		return
+	}
+
+	plain, err := mc.decrypt(echostr)
+	if err != nil {
+		slog.Warn("企业微信 echostr 解密失败", "error", err)
+		c.String(http.StatusForbidden, "解密失败")
+		return
+	}
+	c.String(http.StatusOK, string(plain))
+}
+
+// HandleMessage 接收企业微信推送的消息，立即返回空响应，答案准备好后通过应用消息发送
+func (wh *WeComHandler) HandleMessage(c *gin.Context) {
+	defer c.Request.Body.Close()
+	body, _ := ioutil.ReadAll(c.Request.Body)
+
+	mc, err := wh.app.getMsgCrypt()
+	if err != nil {
+		slog.Error("企业微信消息加解密配置错误", "error", err)
+		c.String(http.StatusOK, "")
+		return
+	}
+
+	plainBody, err := mc.decryptRequest(body, c.Query("msg_signature"), c.Query("timestamp"), c.Query("nonce"))
+	if err != nil {
+		slog.Warn("企业微信消息解密失败", "error", err)
+		c.String(http.StatusForbidden, "签名校验失败")
+		return
+	}
+
+	var msg models.WeChatMessage
+	if err := xml.Unmarshal(plainBody, &msg); err != nil {
+		slog.Warn("企业微信消息解析失败", "error", err)
+		c.String(http.StatusOK, "")
+		return
+	}
+
+	// 返回空串表示已收到，企业微信不会重试
+	c.String(http.StatusOK, "")
+
+	metrics.MessagesReceived.Inc(wh.h.account.Name, msg.MsgType)
+
+	ctx := logging.NewContext(messageRequestID(msg))
+	logging.FromContext(ctx).Info("收到企业微信消息", "user_id", msg.FromUserName, "msg_type", msg.MsgType)
+
+	switch msg.MsgType {
+	case "text":
+		wh.handleText(ctx, msg)
+	case "voice":
+		wh.reply(ctx, msg.FromUserName, "暂不支持语音消息，请发送文字提问。")
+	case "event":
+		logging.FromContext(ctx).Info("企业微信事件", "event", msg.Event)
+	default:
+		wh.reply(ctx, msg.FromUserName, "暂不支持该类型的消息，请发送文字提问。")
+	}
+}
+
+// handleText 处理文本提问或指令
+func (wh *WeComHandler) handleText(ctx context.Context, msg models.WeChatMessage) {
+	h := wh.h
+	userID := msg.FromUserName
+	msgID := msg.MsgId
+	logging.FromContext(ctx).Info("企业微信用户提问", logging.Body("question", msg.Content))
+
+	// 对消息ID排重，避免重复发送
+	if _, exists := h.lookupMessage(msgID); exists {
+		return
+	}
+
+	isCommand := strings.HasPrefix(msg.Content, "/")
+	if reply, ok := h.admitUser(ctx, userID, !isCommand); !ok {
+		if reply != "" {
+			wh.reply(ctx, userID, reply)
+		}
+		return
+	}
+
+	if isCommand {
+		wh.reply(ctx, userID, h.commandReply(ctx, msg))
+		return
+	}
+
+	moderated := h.moderator.Check(ctx, moderation.StageQuestion, userID, msg.Content)
+	switch moderated.Action {
+	case moderation.ActionBlock:
+		return
+	case moderation.ActionReplace:
+		wh.reply(ctx, userID, moderated.Text)
+		return
+	}
+	msg.Content = moderated.Text
+
+	h.saveNewAnswer(&store.Answer{
+		MsgID:         msgID,
+		UserID:        userID,
+		Question:      msg.Content,
+		ProcessingMsg: "您的问题正在处理中，答案准备好后将自动发送给您。",
+		CreatedAt:     time.Now(),
+	})
+
+	ahead, err := wh.submit(ctx, msgID, userID, msg.Content)
+	if err != nil {
+		logging.FromContext(ctx).Warn("提交任务失败", "error", err)
+		h.storeAnswer(msgID, busyMessage)
+		wh.reply(ctx, userID, busyMessage)
+		return
+	}
+
+	if ahead > 0 {
+		wh.reply(ctx, userID, fmt.Sprintf("您的问题已进入排队，前面还有%d个问题，答案准备好后将自动发送给您。", ahead))
+	}
+}
+
+// submit 将获取答案的任务加入队列，答案准备好后通过应用消息发送，返回排在前面的问题数
+func (wh *WeComHandler) submit(ctx context.Context, msgID, userID, question string) (int, error) {
+	h := wh.h
+	_, ahead, err := h.pool.Submit(userID, func() string {
+		answer, err := h.getAnswerForQuestion(ctx, msgID, question, userID)
+		if err != nil {
+			answer = fmt.Sprintf("抱歉，获取答案失败: %v", err)
+		}
+
+		h.storeAnswer(msgID, answer)
+		wh.reply(ctx, userID, h.prepareAnswerReply(ctx, userID, answer))
+		h.updateAnswer(msgID, func(a *store.Answer) {
+			a.Pushed = true
+		})
+		return answer
+	})
+	return ahead, err
+}
+
+// reply 通过应用消息接口发送文本，失败时记录日志
+func (wh *WeComHandler) reply(ctx context.Context, userID, content string) {
+	if err := wh.app.SendTextMessage(ctx, userID, cleanAnswer(content)); err != nil {
+		logging.FromContext(ctx).Warn("企业微信发送消息失败", "error", err)
+	}
+}
+
+// SendTextMessage 通过应用消息接口向成员发送文本消息，access_token 失效时刷新后重试一次
+func (w *WeComApp) SendTextMessage(ctx context.Context, userID, content string) error {
+	reqBody := map[string]interface{}{
+		"touser":  userID,
+		"msgtype": "text",
+		"agentid": w.config().AgentID,
+		"text": map[string]string{
+			"content": content,
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	errCode, errMsg, err := w.tokens.post(ctx, w.tokenURL(), func(token string) (int, string, error) {
+		return w.postMessage(token, body)
+	})
+	if err != nil {
+		return err
+	}
+	if errCode != 0 {
+		return fmt.Errorf("发送应用消息失败: %d - %s", errCode, errMsg)
+	}
+	return nil
+}
+
+// postMessage 调用应用消息发送接口，返回企业微信的错误码
+func (w *WeComApp) postMessage(token string, body []byte) (int, string, error) {
+	sendURL := w.config().SendURL
+	if sendURL == "" {
+		sendURL = defaultWeComSendURL
+	}
+
+	client := &http.Client{Timeout: wecomSendRequestTimeout}
+	resp, err := client.Post(fmt.Sprintf(sendURL, token), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return 0, "", fmt.Errorf("解析应用消息响应失败: %w", err)
+	}
+
+	return result.ErrCode, result.ErrMsg, nil
+}
@@ -8,7 +8,7 @@ RAGFLOW_PORT=9380
 RAGFLOW_ALT_PORT=8081
 FALLBACK_RAGFLOW_HOST="114.215.255.105"
 FALLBACK_RAGFLOW_PORT="8081"
-CONFIG_FILE="/app/config.yml"
+CONFIG_FILE="${WECHAT_RAG_CONFIG:-/app/config.yml}"
 
 echo "WeChat-RAG 智能网络自修复启动脚本"
 echo "===================================="
@@ -154,4 +154,4 @@ echo "配置文件: $CONFIG_FILE"
 echo "===================================="
 
 # 启动主程序
-exec /app/wechat-rag
+exec /app/wechat-rag -config "$CONFIG_FILE"
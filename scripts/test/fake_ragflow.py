@@ -0,0 +1,102 @@
+#!/usr/bin/env python3
+"""离线模拟RAGFlow服务，按录制的SSE样本返回流式响应。
+
+用法: python3 fake_ragflow.py [端口] [SSE样本文件] [事件间隔秒数]
+将 config.yml 中的 ragflow.base_url 指向 http://localhost:<端口> 即可离线测试。
+设置环境变量 FAKE_LLM_DOWN=1 可模拟模型服务不可用(OpenAI兼容接口返回500)。
+"""
+import json
+import os
+import re
+import sys
+import time
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+PORT = int(sys.argv[1]) if len(sys.argv) > 1 else 19380
+FIXTURE = sys.argv[2] if len(sys.argv) > 2 else os.path.join(os.path.dirname(__file__), "fixtures", "ragflow_stream.sse")
+DELAY = float(sys.argv[3]) if len(sys.argv) > 3 else 0.5
+LLM_DOWN = os.environ.get("FAKE_LLM_DOWN") == "1"
+
+
+def load_events(path):
+    with open(path, encoding="utf-8") as f:
+        return [e + "\n\n" for e in f.read().split("\n\n") if e.strip()]
+
+
+def final_payload(events):
+    """非流式请求返回样本中最后一个带答案的事件。"""
+    answer = None
+    for event in events:
+        for line in event.splitlines():
+            if line.startswith("data:"):
+                data = json.loads(line[5:])
+                if isinstance(data.get("data"), dict):
+                    answer = data
+    return answer or {"code": 0, "data": {"answer": ""}}
+
+
+def retrieval_chunks(events):
+    """检索接口返回样本最后一个事件引用中的知识片段，字段与RAGFlow检索接口一致。"""
+    payload = final_payload(events)
+    reference = payload.get("data", {}).get("reference") or {}
+    return [
+        {
+            "id": c.get("id"),
+            "content": c.get("content", ""),
+            "document_id": c.get("document_id"),
+            "document_keyword": c.get("document_name", ""),
+            "similarity": c.get("similarity", 0),
+        }
+        for c in reference.get("chunks", [])
+    ]
+
+
+class Handler(BaseHTTPRequestHandler):
+    def _json(self, obj):
+        body = json.dumps(obj, ensure_ascii=False).encode("utf-8")
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def do_POST(self):
+        length = int(self.headers.get("Content-Length", 0))
+        req = json.loads(self.rfile.read(length) or b"{}")
+
+        if re.match(r"^/api/v1/chats/[^/]+/sessions$", self.path):
+            self._json({"code": 0, "data": {"id": "fake_session", "name": req.get("name", "")}})
+        elif self.path == "/api/v1/retrieval":
+            chunks = retrieval_chunks(load_events(FIXTURE))
+            self._json({"code": 0, "data": {"chunks": chunks, "total": len(chunks)}})
+        elif re.match(r"^/api/v1/chats_openai/[^/]+/chat/completions$", self.path):
+            if LLM_DOWN:
+                self.send_response(500)
+                self.end_headers()
+                return
+            prompt = req.get("messages", [{}])[-1].get("content", "")
+            content = f"根据知识库(提示词{len(prompt)}字)：浙江腾视科技有限公司成立于2017年。"
+            self._json({"choices": [{"message": {"role": "assistant", "content": content}}]})
+        elif re.match(r"^/api/v1/chats/[^/]+/completions$", self.path):
+            events = load_events(FIXTURE)
+            if not req.get("stream"):
+                self._json(final_payload(events))
+                return
+            self.send_response(200)
+            self.send_header("Content-Type", "text/event-stream; charset=utf-8")
+            self.end_headers()
+            for event in events:
+                self.wfile.write(event.encode("utf-8"))
+                self.wfile.flush()
+                time.sleep(DELAY)
+        else:
+            self.send_response(404)
+            self.end_headers()
+
+    def do_DELETE(self):
+        self._json({"code": 0})
+
+
+if __name__ == "__main__":
+    print(f"模拟RAGFlow服务监听 :{PORT}，SSE样本: {FIXTURE}")
+    ThreadingHTTPServer(("", PORT), Handler).serve_forever()
@@ -0,0 +1,47 @@
+#!/usr/bin/env python3
+"""离线模拟企业微信接口，打印收到的应用消息。
+
+用法: python3 fake_wecom.py [端口]
+将 config.yml 中 wecom.token_url 和 wecom.send_url 指向:
+  http://localhost:<端口>/cgi-bin/gettoken?corpid=%s&corpsecret=%s
+  http://localhost:<端口>/cgi-bin/message/send?access_token=%s
+"""
+import json
+import sys
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+PORT = int(sys.argv[1]) if len(sys.argv) > 1 else 19390
+
+
+class Handler(BaseHTTPRequestHandler):
+    def _json(self, obj):
+        body = json.dumps(obj, ensure_ascii=False).encode("utf-8")
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def do_GET(self):
+        if self.path.startswith("/cgi-bin/gettoken"):
+            self._json({"errcode": 0, "errmsg": "ok", "access_token": "fake_wecom_token", "expires_in": 7200})
+        else:
+            self._json({"errcode": 404, "errmsg": "not found"})
+
+    def do_POST(self):
+        length = int(self.headers.get("Content-Length", 0))
+        req = json.loads(self.rfile.read(length) or b"{}")
+        if self.path.startswith("/cgi-bin/message/send"):
+            print(f"应用消息 -> {req.get('touser')} (agentid={req.get('agentid')}):")
+            print(req.get("text", {}).get("content", ""), flush=True)
+            self._json({"errcode": 0, "errmsg": "ok"})
+        else:
+            self._json({"errcode": 404, "errmsg": "not found"})
+
+    def log_message(self, *args):
+        pass
+
+
+if __name__ == "__main__":
+    print(f"模拟企业微信接口监听 :{PORT}", flush=True)
+    ThreadingHTTPServer(("", PORT), Handler).serve_forever()
@@ -0,0 +1,29 @@
+#!/bin/bash
+# 管理接口测试：查看用户消息、处理中的答案、会话ID，并强制清除会话
+# 用法: ADMIN_TOKEN=<token> ./test_admin.sh [用户OpenID]
+
+ADMIN_URL=${ADMIN_URL:-"http://localhost/admin"}
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
+USER_ID=${1:-"test_user_789"}
+
+AUTH_HEADER="Authorization: Bearer $ADMIN_TOKEN"
+
+echo "1. 未携带令牌访问(应返回401或403)"
+curl -s -o /dev/null -w "HTTP %{http_code}\n" "$ADMIN_URL/answers"
+echo "------------------------"
+
+echo "2. 用户 $USER_ID 最近的消息"
+curl -s -H "$AUTH_HEADER" "$ADMIN_URL/users/$USER_ID/messages?limit=5"
+echo -e "\n------------------------"
+
+echo "3. 处理中的答案"
+curl -s -H "$AUTH_HEADER" "$ADMIN_URL/answers?status=pending"
+echo -e "\n------------------------"
+
+echo "4. 用户 $USER_ID 的RAGFlow会话"
+curl -s -H "$AUTH_HEADER" "$ADMIN_URL/users/$USER_ID/session"
+echo -e "\n------------------------"
+
+echo "5. 强制清除用户 $USER_ID 的会话"
+curl -s -X DELETE -H "$AUTH_HEADER" "$ADMIN_URL/users/$USER_ID/session"
+echo -e "\n------------------------"
@@ -0,0 +1,47 @@
+#!/bin/bash
+# 流式回复测试：使用录制的SSE样本模拟RAGFlow，验证首段先行回复
+# 运行前请将 config.yml 中的 ragflow.base_url 设置为 http://localhost:19380，并开启 ragflow.stream
+
+SCRIPT_DIR=$(cd "$(dirname "$0")" && pwd)
+SERVER_URL=${SERVER_URL:-"http://localhost/wechat"}
+FIXTURE=${1:-"$SCRIPT_DIR/fixtures/ragflow_stream.sse"}
+
+# 启动模拟RAGFlow服务，事件间隔0.5秒，保证首段在4秒内到达而完整答案超过4秒
+python3 "$SCRIPT_DIR/fake_ragflow.py" 19380 "$FIXTURE" 0.5 &
+FAKE_PID=$!
+trap 'kill $FAKE_PID 2>/dev/null' EXIT
+sleep 1
+
+echo "1. 直接请求模拟服务的流式接口"
+echo "------------------------"
+curl -s -N -X POST "http://localhost:19380/api/v1/chats/test/completions" \
+  -H "Content-Type: application/json" \
+  -d '{"question": "介绍一下公司", "session_id": "fake_session", "stream": true}' | head -c 300
+echo -e "\n------------------------"
+
+echo "2. 通过微信接口提问 (应先行返回首段内容)"
+echo "------------------------"
+XML_MSG="<xml>
+  <ToUserName>gh_123456789abc</ToUserName>
+  <FromUserName>test_user_stream</FromUserName>
+  <CreateTime>$(date +%s)</CreateTime>
+  <MsgType>text</MsgType>
+  <Content>介绍一下公司</Content>
+  <MsgId>$(date +%s%N)</MsgId>
+</xml>"
+curl -s -X POST "$SERVER_URL" -H "Content-Type: application/xml" -d "$XML_MSG"
+echo -e "\n------------------------"
+
+echo "3. 等待完整答案后发送 /more 获取剩余内容"
+echo "------------------------"
+sleep 8
+XML_MSG="<xml>
+  <ToUserName>gh_123456789abc</ToUserName>
+  <FromUserName>test_user_stream</FromUserName>
+  <CreateTime>$(date +%s)</CreateTime>
+  <MsgType>text</MsgType>
+  <Content>/more</Content>
+  <MsgId>$(date +%s%N)</MsgId>
+</xml>"
+curl -s -X POST "$SERVER_URL" -H "Content-Type: application/xml" -d "$XML_MSG"
+echo -e "\n------------------------"
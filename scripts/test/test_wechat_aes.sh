@@ -0,0 +1,80 @@
+#!/bin/bash
+# 安全模式(AES加密)消息回调测试
+# 使用固定的测试向量在本地完成加密、签名和解密，不依赖微信服务器。
+# 运行前请确保 config.yml 中的 token、app_id、encoding_aes_key 与下方一致。
+
+SERVER_URL=${SERVER_URL:-"http://localhost/wechat"}
+TOKEN=${TOKEN:-"wechat_rag_token"}
+APP_ID=${APP_ID:-"wx39fc841a05350758"}
+ENCODING_AES_KEY=${ENCODING_AES_KEY:-"abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"}
+
+# 固定的测试向量
+TIMESTAMP="1700000000"
+NONCE="1320562132"
+RANDOM_PREFIX="0123456789abcdef"
+QUESTION=${1:-"/help"}
+
+WORK_DIR=$(mktemp -d)
+trap 'rm -rf "$WORK_DIR"' EXIT
+
+# AESKey = Base64_Decode(EncodingAESKey + "=")，IV 取 AESKey 前16字节
+AES_KEY_HEX=$(printf '%s=' "$ENCODING_AES_KEY" | base64 -d | od -An -tx1 -v | tr -d ' \n')
+IV_HEX=${AES_KEY_HEX:0:32}
+
+# 明文消息
+printf '<xml><ToUserName><![CDATA[gh_123456789abc]]></ToUserName><FromUserName><![CDATA[test_user_aes]]></FromUserName><CreateTime>%s</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[%s]]></Content><MsgId>1234567890123456</MsgId></xml>' \
+  "$TIMESTAMP" "$QUESTION" > "$WORK_DIR/msg.xml"
+MSG_LEN=$(wc -c < "$WORK_DIR/msg.xml")
+
+# 拼接: 16字节随机串 + 4字节消息长度(网络字节序) + 消息 + AppID
+{
+  printf '%s' "$RANDOM_PREFIX"
+  printf "$(printf '\\x%02x\\x%02x\\x%02x\\x%02x' $((MSG_LEN >> 24 & 255)) $((MSG_LEN >> 16 & 255)) $((MSG_LEN >> 8 & 255)) $((MSG_LEN & 255)))"
+  cat "$WORK_DIR/msg.xml"
+  printf '%s' "$APP_ID"
+} > "$WORK_DIR/plain.bin"
+
+# 按32字节块进行PKCS#7填充
+PLAIN_LEN=$(wc -c < "$WORK_DIR/plain.bin")
+PAD=$((32 - PLAIN_LEN % 32))
+for _ in $(seq 1 $PAD); do
+  printf "$(printf '\\x%02x' $PAD)"
+done >> "$WORK_DIR/plain.bin"
+
+ENCRYPT=$(openssl enc -aes-256-cbc -nopad -K "$AES_KEY_HEX" -iv "$IV_HEX" -in "$WORK_DIR/plain.bin" | base64 | tr -d '\n')
+
+# msg_signature = sha1(sort(token, timestamp, nonce, encrypt))
+MSG_SIGNATURE=$(printf '%s\n' "$TOKEN" "$TIMESTAMP" "$NONCE" "$ENCRYPT" | LC_ALL=C sort | tr -d '\n' | sha1sum | awk '{print $1}')
+
+echo "发送加密消息: $QUESTION"
+echo "Encrypt: $ENCRYPT"
+echo "msg_signature: $MSG_SIGNATURE"
+echo "------------------------"
+
+curl -s -X POST "$SERVER_URL?timestamp=$TIMESTAMP&nonce=$NONCE&encrypt_type=aes&msg_signature=$MSG_SIGNATURE" \
+  -H "Content-Type: application/xml" \
+  -d "<xml><ToUserName><![CDATA[gh_123456789abc]]></ToUserName><Encrypt><![CDATA[$ENCRYPT]]></Encrypt></xml>" > "$WORK_DIR/response.xml"
+
+cat "$WORK_DIR/response.xml"
+echo -e "\n------------------------"
+
+# 校验并解密被动回复
+RESP_ENCRYPT=$(sed -n 's/.*<Encrypt><!\[CDATA\[\(.*\)\]\]><\/Encrypt>.*/\1/p' "$WORK_DIR/response.xml")
+RESP_SIGNATURE=$(sed -n 's/.*<MsgSignature><!\[CDATA\[\(.*\)\]\]><\/MsgSignature>.*/\1/p' "$WORK_DIR/response.xml")
+RESP_TIMESTAMP=$(sed -n 's/.*<TimeStamp>\(.*\)<\/TimeStamp>.*/\1/p' "$WORK_DIR/response.xml")
+
+if [[ -z "$RESP_ENCRYPT" ]]; then
+  echo "❌ 响应中没有 Encrypt 字段"
+  exit 1
+fi
+
+EXPECTED_SIGNATURE=$(printf '%s\n' "$TOKEN" "$RESP_TIMESTAMP" "$NONCE" "$RESP_ENCRYPT" | LC_ALL=C sort | tr -d '\n' | sha1sum | awk '{print $1}')
+if [[ "$EXPECTED_SIGNATURE" != "$RESP_SIGNATURE" ]]; then
+  echo "❌ 回复签名校验失败"
+  exit 1
+fi
+echo "✅ 回复签名校验通过"
+
+# 去掉16字节随机串和4字节长度后即为回复XML(末尾为AppID和填充)
+printf '%s' "$RESP_ENCRYPT" | base64 -d | openssl enc -d -aes-256-cbc -nopad -K "$AES_KEY_HEX" -iv "$IV_HEX" | tail -c +21 | tr -d '\000-\010\013-\037'
+echo -e "\n------------------------"
@@ -0,0 +1,31 @@
+#!/bin/bash
+
+# 微信事件消息XML模板
+XML_TEMPLATE='<xml>
+  <ToUserName>gh_123456789abc</ToUserName>
+  <FromUserName>test_user_event</FromUserName>
+  <CreateTime>%s</CreateTime>
+  <MsgType>event</MsgType>
+  <Event>%s</Event>
+  <EventKey>%s</EventKey>
+</xml>'
+
+# 事件类型和EventKey，默认测试关注事件
+EVENT=${1:-"subscribe"}
+EVENT_KEY=${2:-""}
+
+# 当前时间戳
+TIMESTAMP=$(date +%s)
+
+# 生成XML消息
+XML_MSG=$(printf "$XML_TEMPLATE" "$TIMESTAMP" "$EVENT" "$EVENT_KEY")
+
+echo "发送事件: $EVENT $EVENT_KEY"
+echo "------------------------"
+
+# 发送请求到微信处理接口
+curl -s -X POST "http://localhost/wechat" \
+  -H "Content-Type: application/xml" \
+  -d "$XML_MSG"
+
+echo -e "\n------------------------"
@@ -0,0 +1,82 @@
+#!/bin/bash
+# 企业微信自建应用回调测试
+# 在本地完成 echostr 和消息体的加密、签名，不依赖企业微信服务器。
+# 运行前请确保 config.yml 中 wecom 的 corp_id、token、encoding_aes_key 与下方一致；
+# 答案通过应用消息接口发送，可配合 fake_wecom.py 查看。
+
+SERVER_URL=${SERVER_URL:-"http://localhost/wecom"}
+TOKEN=${TOKEN:-"wecom_rag_token"}
+CORP_ID=${CORP_ID:-"ww0123456789abcdef"}
+ENCODING_AES_KEY=${ENCODING_AES_KEY:-"abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"}
+
+TIMESTAMP="1700000000"
+NONCE="1320562132"
+RANDOM_PREFIX="0123456789abcdef"
+QUESTION=${1:-"/help"}
+
+WORK_DIR=$(mktemp -d)
+trap 'rm -rf "$WORK_DIR"' EXIT
+
+# AESKey = Base64_Decode(EncodingAESKey + "=")，IV 取 AESKey 前16字节
+AES_KEY_HEX=$(printf '%s=' "$ENCODING_AES_KEY" | base64 -d | od -An -tx1 -v | tr -d ' \n')
+IV_HEX=${AES_KEY_HEX:0:32}
+
+# encrypt_file 加密文件内容: 16字节随机串 + 4字节长度 + 内容 + CorpID，PKCS#7(32字节)填充
+encrypt_file() {
+  local len
+  len=$(wc -c < "$1")
+  {
+    printf '%s' "$RANDOM_PREFIX"
+    printf "$(printf '\\x%02x\\x%02x\\x%02x\\x%02x' $((len >> 24 & 255)) $((len >> 16 & 255)) $((len >> 8 & 255)) $((len & 255)))"
+    cat "$1"
+    printf '%s' "$CORP_ID"
+  } > "$WORK_DIR/plain.bin"
+
+  local plain_len pad
+  plain_len=$(wc -c < "$WORK_DIR/plain.bin")
+  pad=$((32 - plain_len % 32))
+  for _ in $(seq 1 $pad); do
+    printf "$(printf '\\x%02x' $pad)"
+  done >> "$WORK_DIR/plain.bin"
+
+  openssl enc -aes-256-cbc -nopad -K "$AES_KEY_HEX" -iv "$IV_HEX" -in "$WORK_DIR/plain.bin" | base64 | tr -d '\n'
+}
+
+# signature 计算 sha1(sort(token, timestamp, nonce, encrypt))
+signature() {
+  printf '%s\n' "$TOKEN" "$TIMESTAMP" "$NONCE" "$1" | LC_ALL=C sort | tr -d '\n' | sha1sum | awk '{print $1}'
+}
+
+urlencode() {
+  python3 -c 'import sys, urllib.parse; print(urllib.parse.quote(sys.argv[1], safe=""))' "$1"
+}
+
+echo "1. 验证回调URL"
+printf 'echo_%s' "$NONCE" > "$WORK_DIR/echo.txt"
+ECHOSTR=$(encrypt_file "$WORK_DIR/echo.txt")
+ECHO_RESULT=$(curl -s "$SERVER_URL?msg_signature=$(signature "$ECHOSTR")&timestamp=$TIMESTAMP&nonce=$NONCE&echostr=$(urlencode "$ECHOSTR")")
+if [[ "$ECHO_RESULT" == "echo_$NONCE" ]]; then
+  echo "✅ URL验证通过: $ECHO_RESULT"
+else
+  echo "❌ URL验证失败: $ECHO_RESULT"
+  exit 1
+fi
+echo "------------------------"
+
+echo "2. 发送加密消息: $QUESTION"
+printf '<xml><ToUserName><![CDATA[%s]]></ToUserName><FromUserName><![CDATA[test_staff]]></FromUserName><CreateTime>%s</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[%s]]></Content><MsgId>%s</MsgId><AgentID>1000002</AgentID></xml>' \
+  "$CORP_ID" "$TIMESTAMP" "$QUESTION" "$(date +%s%N)" > "$WORK_DIR/msg.xml"
+ENCRYPT=$(encrypt_file "$WORK_DIR/msg.xml")
+
+HTTP_CODE=$(curl -s -o "$WORK_DIR/response.txt" -w '%{http_code}' -X POST \
+  "$SERVER_URL?msg_signature=$(signature "$ENCRYPT")&timestamp=$TIMESTAMP&nonce=$NONCE" \
+  -H "Content-Type: application/xml" \
+  -d "<xml><ToUserName><![CDATA[$CORP_ID]]></ToUserName><AgentID><![CDATA[1000002]]></AgentID><Encrypt><![CDATA[$ENCRYPT]]></Encrypt></xml>")
+
+if [[ "$HTTP_CODE" == "200" ]]; then
+  echo "✅ 消息已接收，答案将通过应用消息接口发送"
+else
+  echo "❌ 消息处理失败: HTTP $HTTP_CODE $(cat "$WORK_DIR/response.txt")"
+  exit 1
+fi
+echo "------------------------"